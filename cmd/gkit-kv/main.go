@@ -0,0 +1,12 @@
+// gkit-kv 查看与编辑 kv.KVStore 的持久化文件，用法见 gkit-kv help 与 kvcli 包文档
+package main
+
+import (
+	"os"
+
+	"github.com/Yuelioi/gkit/utils/kv/kvcli"
+)
+
+func main() {
+	os.Exit(kvcli.Main(os.Args[1:], os.Stdout, os.Stderr))
+}
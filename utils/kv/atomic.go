@@ -0,0 +1,103 @@
+package kv
+
+import (
+	"reflect"
+	"time"
+)
+
+// ---------------------------
+// 原子读改写操作
+// ---------------------------
+//
+// 以下方法在同一次写锁内完成读取与写入，已过期的键一律视为不存在。
+// 传入的回调在持锁状态下执行，不能再调用同一个 KVStore 的方法。
+
+// liveLocked 返回未过期的条目（调用方需持有 sh 的锁）
+func liveLocked[V any](sh *shard[V], key string, now int64) (item[V], bool) {
+	it, ok := sh.data[key]
+	if !ok || it.expired(now) {
+		return item[V]{}, false
+	}
+	return it, true
+}
+
+func expireAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(ttl).UnixNano()
+}
+
+// CompareAndSwap 当 key 存在且当前值等于 old（reflect.DeepEqual）时替换为 new，保留原有过期时间
+func (s *KVStore[V]) CompareAndSwap(key string, old, new V) bool {
+	sh := s.lock(key)
+	defer s.unlock(sh, key)
+	it, ok := liveLocked(sh, key, time.Now().UnixNano())
+	if !ok || !reflect.DeepEqual(it.Value, old) {
+		return false
+	}
+	it.Value = new
+	s.putLocked(sh, key, it)
+	return true
+}
+
+// SetNX 仅当 key 不存在（或已过期）时写入，返回是否写入成功（ttl <= 0 表示不过期）
+func (s *KVStore[V]) SetNX(key string, value V, ttl time.Duration) bool {
+	sh := s.lock(key)
+	defer s.unlock(sh, key)
+	if _, ok := liveLocked(sh, key, time.Now().UnixNano()); ok {
+		return false
+	}
+	s.putLocked(sh, key, s.newItem(value, ttl))
+	return true
+}
+
+// GetOrSet 返回已有值（loaded = true）；不存在时写入 value 并返回它（loaded = false）
+func (s *KVStore[V]) GetOrSet(key string, value V, ttl time.Duration) (actual V, loaded bool) {
+	sh := s.lock(key)
+	defer s.unlock(sh, key)
+	if it, ok := liveLocked(sh, key, time.Now().UnixNano()); ok {
+		return it.Value, true
+	}
+	s.putLocked(sh, key, s.newItem(value, ttl))
+	return value, false
+}
+
+// Update 以 fn 的结果原子地更新 key。
+// fn 收到当前值与是否存在；返回 keep = false 表示删除该键。
+// 已存在的键保留原有过期时间，新键不过期。返回更新后的值与是否存在。
+func (s *KVStore[V]) Update(key string, fn func(old V, ok bool) (V, bool)) (V, bool) {
+	sh := s.lock(key)
+	defer s.unlock(sh, key)
+	it, ok := liveLocked(sh, key, time.Now().UnixNano())
+	value, keep := fn(it.Value, ok)
+	if !keep {
+		s.dropLocked(sh, key, ok)
+		var zero V
+		return zero, false
+	}
+	it.Value = value
+	s.putLocked(sh, key, it)
+	return value, true
+}
+
+// GetAndDelete 删除 key 并返回删除前的值
+func (s *KVStore[V]) GetAndDelete(key string) (V, bool) {
+	sh := s.lock(key)
+	defer s.unlock(sh, "")
+	it, ok := liveLocked(sh, key, time.Now().UnixNano())
+	s.dropLocked(sh, key, ok)
+	return it.Value, ok
+}
+
+// dropLocked 删除 key（如存在）；live 为 false 时按过期处理
+func (s *KVStore[V]) dropLocked(sh *shard[V], key string, live bool) {
+	if _, exists := sh.data[key]; !exists {
+		return
+	}
+	if live {
+		s.removeLocked(sh, key, EventDelete)
+	} else {
+		s.removeLocked(sh, key, EventExpire)
+	}
+}
@@ -0,0 +1,104 @@
+package kv_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+func TestKVStore_UpdateConcurrent(t *testing.T) {
+	store, err := kv.NewKVStore[int]("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				store.Update("counter", func(old int, ok bool) (int, bool) {
+					return old + 1, true
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v, _ := store.Get("counter"); v != 5000 {
+		t.Fatalf("expected 5000, got %d", v)
+	}
+
+	// keep = false 删除
+	store.Update("counter", func(old int, ok bool) (int, bool) { return 0, false })
+	if store.Exists("counter") {
+		t.Fatal("expected counter deleted")
+	}
+}
+
+func TestKVStore_SetNX(t *testing.T) {
+	store, err := kv.NewKVStore[string]("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	winners := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if store.SetNX("lock", "owner", 30*time.Millisecond) {
+				mu.Lock()
+				winners++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if winners != 1 {
+		t.Fatalf("expected exactly one winner, got %d", winners)
+	}
+
+	// 过期后可以再次获取
+	time.Sleep(50 * time.Millisecond)
+	if !store.SetNX("lock", "owner2", 0) {
+		t.Fatal("expected SetNX to succeed after expiry")
+	}
+}
+
+func TestKVStore_CASAndFriends(t *testing.T) {
+	store, err := kv.NewKVStore[string]("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if v, loaded := store.GetOrSet("k", "a", time.Hour); loaded || v != "a" {
+		t.Fatalf("expected stored a, got %v %v", v, loaded)
+	}
+	if v, loaded := store.GetOrSet("k", "b", 0); !loaded || v != "a" {
+		t.Fatalf("expected loaded a, got %v %v", v, loaded)
+	}
+	if store.CompareAndSwap("k", "x", "c") {
+		t.Fatal("expected CAS to fail on mismatch")
+	}
+	if !store.CompareAndSwap("k", "a", "c") {
+		t.Fatal("expected CAS to succeed")
+	}
+	if ttl := store.TTL("k"); ttl.State != kv.TTLExpiring || ttl.Remaining <= 0 {
+		t.Fatalf("expected CAS to keep ttl, got %v", ttl)
+	}
+	if v, ok := store.GetAndDelete("k"); !ok || v != "c" {
+		t.Fatalf("expected c, got %v %v", v, ok)
+	}
+	if _, ok := store.GetAndDelete("k"); ok {
+		t.Fatal("expected key gone")
+	}
+}
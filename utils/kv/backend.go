@@ -0,0 +1,206 @@
+package kv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ---------------------------
+// 存储后端
+// ---------------------------
+//
+// NewKVStore(filePath) 使用内置的文件持久化（支持 WAL、备份、文件锁、热加载等）；
+// NewKVStoreWithBackend 把持久化交给 Backend，内存中的行为与 API 完全相同，
+// 文件相关的选项（WAL、备份、文件锁、热加载）不生效。
+// 后端实现了 DeltaBackend 时，保存只提交自上次保存以来变化的键。
+
+// Entry 后端中的一条记录
+type Entry[V any] struct {
+	Value    V
+	ExpireAt time.Time     // 零值表示不过期
+	Sliding  time.Duration // 滑动过期窗口，0 表示不滑动
+	Tags     []string      // 标签，见 SetWithTags
+}
+
+// Change 一次增量变更；Deleted 为 true 时 Entry 为零值
+type Change[V any] struct {
+	Key     string
+	Entry   Entry[V]
+	Deleted bool
+}
+
+// Backend 存储后端
+type Backend[V any] interface {
+	// Load 读取全部记录
+	Load(ctx context.Context) (map[string]Entry[V], error)
+	// Save 用 snapshot 整体替换后端内容
+	Save(ctx context.Context, snapshot map[string]Entry[V]) error
+}
+
+// DeltaBackend 支持增量提交的后端
+type DeltaBackend[V any] interface {
+	Backend[V]
+	// Apply 按顺序提交变更，需保证全部成功或全部失败
+	Apply(ctx context.Context, changes []Change[V]) error
+}
+
+// NewKVStoreWithBackend 创建使用 b 持久化的 KVStore，其余行为同 NewKVStore
+func NewKVStoreWithBackend[V any](b Backend[V], opts ...Option) (*KVStore[V], error) {
+	return newStore[V]("", b, opts)
+}
+
+// SetEntry 按 e 写入 key，保留绝对过期时间、滑动窗口与标签（用于导入与迁移）；e 已过期时视为过期的键
+func (s *KVStore[V]) SetEntry(key string, e Entry[V]) {
+	it := fromEntry(e)
+	sh := s.lock(key)
+	defer s.unlock(sh, key)
+	s.putLocked(sh, key, it)
+}
+
+func toEntry[V any](it item[V]) Entry[V] {
+	e := Entry[V]{Value: it.Value, Sliding: time.Duration(it.Slide), Tags: it.Tags}
+	if it.ExpireAt > 0 {
+		e.ExpireAt = time.Unix(0, it.ExpireAt)
+	}
+	return e
+}
+
+func fromEntry[V any](e Entry[V]) item[V] {
+	it := item[V]{Value: e.Value, Slide: int64(e.Sliding), Tags: e.Tags}
+	if !e.ExpireAt.IsZero() {
+		it.ExpireAt = e.ExpireAt.UnixNano()
+	}
+	return it
+}
+
+func (s *KVStore[V]) loadBackend() error {
+	entries, err := s.backend.Load(context.Background())
+	if err != nil {
+		return err
+	}
+	data := make(map[string]item[V], len(entries))
+	for k, e := range entries {
+		data[k] = fromEntry(e)
+	}
+	s.lockAll()
+	s.replaceLocked(data)
+	s.dirty.Store(false)
+	s.unlockAll("")
+	return nil
+}
+
+// saveBackend 提交到后端（调用方需持有 saveMu）
+func (s *KVStore[V]) saveBackend() error {
+	if !s.dirty.Load() {
+		return nil
+	}
+	start := time.Now()
+
+	var (
+		snapshot map[string]Entry[V]
+		changes  []Change[V]
+		changed  map[string]struct{}
+	)
+	s.rlockAll()
+	if s.changes != nil {
+		changed = s.changes.take()
+		changes = make([]Change[V], 0, len(changed))
+		for k := range changed {
+			if it, ok := s.shardFor(k).data[k]; ok {
+				changes = append(changes, Change[V]{Key: k, Entry: toEntry(it)})
+			} else {
+				changes = append(changes, Change[V]{Key: k, Deleted: true})
+			}
+		}
+	} else {
+		snapshot = make(map[string]Entry[V], s.count.Load())
+		for _, sh := range s.shards {
+			for k, it := range sh.data {
+				snapshot[k] = toEntry(it)
+			}
+		}
+	}
+	s.dirty.Store(false)
+	s.runlockAll()
+
+	var err error
+	if changed != nil {
+		if len(changes) == 0 {
+			return nil
+		}
+		err = s.backend.(DeltaBackend[V]).Apply(context.Background(), changes)
+	} else {
+		err = s.backend.Save(context.Background(), snapshot)
+	}
+	if err != nil {
+		s.dirty.Store(true)
+		if changed != nil {
+			s.changes.restore(changed)
+		}
+		return err
+	}
+	s.stats.saved(time.Now(), time.Since(start))
+	return nil
+}
+
+// ---------------------------
+// 文件后端
+// ---------------------------
+
+// NewFileBackend 返回以单个文件保存快照的 Backend，格式与 NewKVStore 写出的文件一致。
+// opts 中仅 WithCodec / WithPrettyJSON / WithCompression / WithEncryption 生效。
+func NewFileBackend[V any](path string, opts ...Option) Backend[V] {
+	var cfg config
+	for _, o := range opts {
+		o(&cfg)
+	}
+	f := fileFormat{codec: cfg.codec, compressor: cfg.compressor, keys: cfg.keys}
+	if f.codec == nil {
+		f.codec = jsonCodec{indent: cfg.pretty}
+	}
+	return &fileBackend[V]{path: path, format: f}
+}
+
+type fileBackend[V any] struct {
+	path   string
+	format fileFormat
+}
+
+func (b *fileBackend[V]) Load(ctx context.Context) (map[string]Entry[V], error) {
+	raw, err := os.ReadFile(b.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]Entry[V]{}, nil
+		}
+		return nil, err
+	}
+	entries := make(map[string]Entry[V])
+	if len(raw) == 0 {
+		return entries, nil
+	}
+	data, _, err := decodeFile[V](b.format, raw, false)
+	if err != nil {
+		return nil, err
+	}
+	for k, it := range data {
+		entries[k] = toEntry(it)
+	}
+	return entries, nil
+}
+
+func (b *fileBackend[V]) Save(ctx context.Context, snapshot map[string]Entry[V]) error {
+	data := make(map[string]item[V], len(snapshot))
+	for k, e := range snapshot {
+		data[k] = fromEntry(e)
+	}
+	raw, _, err := b.format.encode(data)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(b.path, raw)
+}
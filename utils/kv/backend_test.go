@@ -0,0 +1,172 @@
+package kv_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+// memBackend 记录调用的内存后端
+type memBackend struct {
+	mu      sync.Mutex
+	data    map[string]kv.Entry[int]
+	saves   int
+	applied [][]kv.Change[int]
+	fail    error
+}
+
+func (b *memBackend) Load(ctx context.Context) (map[string]kv.Entry[int], error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make(map[string]kv.Entry[int], len(b.data))
+	for k, e := range b.data {
+		out[k] = e
+	}
+	return out, nil
+}
+
+func (b *memBackend) Save(ctx context.Context, snapshot map[string]kv.Entry[int]) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.fail != nil {
+		return b.fail
+	}
+	b.saves++
+	b.data = snapshot
+	return nil
+}
+
+// deltaBackend 在 memBackend 基础上支持增量提交
+type deltaBackend struct{ memBackend }
+
+func (b *deltaBackend) Apply(ctx context.Context, changes []kv.Change[int]) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.fail != nil {
+		return b.fail
+	}
+	b.applied = append(b.applied, changes)
+	for _, c := range changes {
+		if c.Deleted {
+			delete(b.data, c.Key)
+		} else {
+			b.data[c.Key] = c.Entry
+		}
+	}
+	return nil
+}
+
+func TestKVStore_Backend(t *testing.T) {
+	b := &memBackend{data: map[string]kv.Entry[int]{
+		"old":  {Value: 1},
+		"ttl":  {Value: 2, ExpireAt: time.Now().Add(time.Hour)},
+		"gone": {Value: 3, ExpireAt: time.Now().Add(-time.Second)},
+	}}
+	store, err := kv.NewKVStoreWithBackend[int](b, kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := store.Get("old"); !ok || v != 1 {
+		t.Fatalf("expected old=1 loaded, got %v %v", v, ok)
+	}
+	if ttl := store.TTL("ttl"); ttl.State != kv.TTLExpiring {
+		t.Fatalf("expected ttl kept, got %+v", ttl)
+	}
+	if store.Exists("gone") {
+		t.Fatal("expected expired entry skipped")
+	}
+
+	store.Set("new", 4)
+	store.Delete("old")
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if b.saves != 1 {
+		t.Fatalf("expected one save, got %d", b.saves)
+	}
+	if _, ok := b.data["old"]; ok {
+		t.Fatal("expected old removed from backend")
+	}
+	if e := b.data["new"]; e.Value != 4 || !e.ExpireAt.IsZero() {
+		t.Fatalf("unexpected new entry %+v", e)
+	}
+}
+
+func TestKVStore_DeltaBackend(t *testing.T) {
+	b := &deltaBackend{memBackend{data: map[string]kv.Entry[int]{"a": {Value: 1}, "b": {Value: 2}}}}
+	store, err := kv.NewKVStoreWithBackend[int](b, kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	store.Set("a", 10)
+	store.Delete("b")
+	store.SetWithTTL("c", 3, time.Hour)
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if b.saves != 0 || len(b.applied) != 1 || len(b.applied[0]) != 3 {
+		t.Fatalf("expected a single 3-key delta, got saves=%d applied=%v", b.saves, b.applied)
+	}
+	if b.data["a"].Value != 10 || b.data["c"].ExpireAt.IsZero() {
+		t.Fatalf("unexpected backend data %+v", b.data)
+	}
+	if _, ok := b.data["b"]; ok {
+		t.Fatal("expected b deleted")
+	}
+
+	// 未变化时不提交
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.applied) != 1 {
+		t.Fatalf("expected no delta for clean store, got %d", len(b.applied))
+	}
+
+	// 失败后变更保留，下次重试
+	b.fail = errors.New("db down")
+	store.Set("d", 4)
+	if err := store.Save(); err == nil {
+		t.Fatal("expected save error")
+	}
+	b.fail = nil
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if b.data["d"].Value != 4 {
+		t.Fatalf("expected d retried, got %+v", b.data)
+	}
+}
+
+func TestFileBackend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backend.db")
+	fb := kv.NewFileBackend[int](path, kv.WithCodec(kv.GobCodec))
+
+	store, err := kv.NewKVStoreWithBackend[int](fb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetWithTTL("a", 1, time.Hour)
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 文件后端写出的文件可以直接被 NewKVStore 读取
+	store2, err := kv.NewKVStore[int](path, kv.WithCodec(kv.GobCodec), kv.WithStrictCodec(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store2.Close()
+	if v, ok := store2.Get("a"); !ok || v != 1 {
+		t.Fatalf("expected a=1, got %v %v", v, ok)
+	}
+	if ttl := store2.TTL("a"); ttl.State != kv.TTLExpiring {
+		t.Fatalf("expected ttl kept, got %+v", ttl)
+	}
+}
@@ -0,0 +1,315 @@
+package kv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ---------------------------
+// 快照导出 / 恢复 / 滚动备份
+// ---------------------------
+//
+// 备份文件与主文件内容格式一致，命名为 <base>.<UTC 时间戳>.bak，
+// 默认放在主文件所在目录。主文件损坏时 load 会回退到最新的有效备份。
+
+const (
+	backupSuffix     = ".bak"
+	backupTimeLayout = "20060102T150405.000000000Z"
+)
+
+// ErrNoBackup 没有满足条件的备份
+var ErrNoBackup = errors.New("kv: no backup found")
+
+// WithBackups 开启滚动备份，保留最近 keep 份（默认 0 不备份）。
+// 每次成功保存后写入一份备份；WAL 模式下即每次压缩与 Close 时。
+func WithBackups(keep int) Option {
+	return func(c *config) { c.backups = keep }
+}
+
+// WithBackupInterval 设置两次备份的最小间隔（默认 0，每次保存都备份）
+func WithBackupInterval(d time.Duration) Option {
+	return func(c *config) { c.backupInterval = d }
+}
+
+// WithBackupDir 设置备份目录（默认与主文件同目录）
+func WithBackupDir(dir string) Option {
+	return func(c *config) { c.backupDir = dir }
+}
+
+// Backup 一份备份文件
+type Backup struct {
+	Path string
+	Time time.Time
+}
+
+type backupSet struct {
+	dir   string
+	base  string
+	keep  int
+	every time.Duration
+	last  time.Time // 最近一次备份时间（受 saveMu 保护）
+}
+
+func newBackupSet(filePath string, cfg config) *backupSet {
+	b := &backupSet{
+		dir:   cfg.backupDir,
+		base:  filepath.Base(filePath),
+		keep:  cfg.backups,
+		every: cfg.backupInterval,
+	}
+	if b.dir == "" {
+		b.dir = filepath.Dir(filePath)
+	}
+	if list, err := b.list(); err == nil && len(list) > 0 {
+		b.last = list[0].Time
+	}
+	return b
+}
+
+// list 返回已有备份，最新的在前
+func (b *backupSet) list() ([]Backup, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	prefix := b.base + "."
+	var list []Backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, backupSuffix) {
+			continue
+		}
+		ts := strings.TrimSuffix(strings.TrimPrefix(name, prefix), backupSuffix)
+		t, err := time.Parse(backupTimeLayout, ts)
+		if err != nil {
+			continue
+		}
+		list = append(list, Backup{Path: filepath.Join(b.dir, name), Time: t})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Time.After(list[j].Time) })
+	return list, nil
+}
+
+// write 在到期时写入一份备份并清理多余的旧备份（调用方需持有 saveMu）
+func (b *backupSet) write(data []byte, now time.Time) error {
+	if b.keep <= 0 || (!b.last.IsZero() && now.Sub(b.last) < b.every) {
+		return nil
+	}
+	if err := os.MkdirAll(b.dir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(b.dir, b.base+"."+now.UTC().Format(backupTimeLayout)+backupSuffix)
+	if err := writeFileAtomic(path, data); err != nil {
+		return err
+	}
+	b.last = now
+	list, err := b.list()
+	if err != nil {
+		return err
+	}
+	for i := b.keep; i < len(list); i++ {
+		if err := removeIfExists(list[i].Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ---------------------------
+// API
+// ---------------------------
+
+// Snapshot 把当前全部数据（含 Bucket）按配置的 Codec 写入 w，格式与持久化文件一致
+func (s *KVStore[V]) Snapshot(w io.Writer) error {
+	s.rlockAll()
+	snap := s.snapshotLocked()
+	s.runlockAll()
+
+	data, _, err := s.encodeFile(snap)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Restore 用 r 中的快照（任意已注册 Codec）替换全部数据，并立即保存。
+// 订阅者会收到被移除键的 EventDelete 与恢复键的 EventSet。
+func (s *KVStore[V]) Restore(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	restored, _, err := s.decodeFile(data, false)
+	if err != nil {
+		return err
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	if s.shared() {
+		if err := s.flock.lockSave(); err != nil {
+			return err
+		}
+		defer s.flock.unlockSave()
+	}
+
+	s.lockAll()
+	if s.watch.observed() {
+		s.shards[0].pending = append(s.shards[0].pending, s.restoreEventsLocked(restored)...)
+	}
+	var replaced []string
+	if s.backend != nil && s.changes != nil {
+		for _, sh := range s.shards {
+			for k := range sh.data {
+				replaced = append(replaced, k)
+			}
+		}
+	}
+	s.replaceLocked(restored)
+	if s.limit != nil {
+		s.resetLimiterLocked()
+	}
+	if s.changes != nil {
+		// 恢复的内容整体覆盖外部文件
+		s.changes.take()
+	}
+	if s.backend != nil {
+		// 增量后端：新旧两份内容中出现过的键都要提交
+		if s.changes != nil {
+			for _, k := range replaced {
+				s.changes.mark(k)
+			}
+			for k := range restored {
+				s.changes.mark(k)
+			}
+		}
+		s.dirty.Store(true)
+		s.unlockAll("")
+		return s.saveBackend()
+	}
+	if s.filePath == "" {
+		s.unlockAll("")
+		return nil
+	}
+	// 与 save 相同：持锁轮转日志，锁外写盘
+	s.dirty.Store(false)
+	if s.wal != nil {
+		if err := s.wal.rotate(); err != nil {
+			s.dirty.Store(true)
+			s.unlockAll("")
+			return err
+		}
+	}
+	s.unlockAll("")
+	return s.writeSnapshot(restored)
+}
+
+// Backups 返回已有的备份（最新的在前）；memory-only 模式返回 nil
+func (s *KVStore[V]) Backups() ([]Backup, error) {
+	if s.backup == nil {
+		return nil, nil
+	}
+	return s.backup.list()
+}
+
+// RestoreAt 恢复到不晚于 t 的最新一份备份，没有时返回 ErrNoBackup
+func (s *KVStore[V]) RestoreAt(t time.Time) error {
+	list, err := s.Backups()
+	if err != nil {
+		return err
+	}
+	for _, b := range list {
+		if b.Time.After(t) {
+			continue
+		}
+		data, err := os.ReadFile(b.Path)
+		if err != nil {
+			return err
+		}
+		return s.Restore(bytes.NewReader(data))
+	}
+	return ErrNoBackup
+}
+
+// restoreEventsLocked 生成用 data 替换当前内容所对应的事件（调用方需持有全部分片的锁）
+func (s *KVStore[V]) restoreEventsLocked(data map[string]item[V]) []Event[V] {
+	now := time.Now().UnixNano()
+	var events []Event[V]
+	for _, sh := range s.shards {
+		for k, old := range sh.data {
+			if _, ok := data[k]; !ok {
+				events = append(events, Event[V]{Type: EventDelete, Key: k, Old: old.Value, HasOld: true})
+			}
+		}
+	}
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		old, existed := s.shardFor(k).data[k]
+		live := existed && !old.expired(now)
+		events = append(events, Event[V]{Type: EventSet, Key: k, Old: old.Value, HasOld: live, New: data[k].Value})
+	}
+	return events
+}
+
+// ---------------------------
+// 加载回退
+// ---------------------------
+
+func decodeSnapshot[E any](data []byte, want Codec, strict bool) (map[string]E, Codec, error) {
+	codec, body, err := detectCodec(data, want, strict)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmp := make(map[string]E)
+	if err := codec.Unmarshal(body, &tmp); err != nil {
+		return nil, nil, err
+	}
+	return tmp, codec, nil
+}
+
+// loadBackup 主文件无法解析时依次尝试备份（从新到旧）。
+// 成功时把损坏的主文件改名为 <filePath>.corrupt 保留现场；全部失败时返回 cause。
+func (s *KVStore[V]) loadBackup(cause error) (map[string]item[V], fileMeta, error) {
+	list, err := s.backup.list()
+	if err != nil {
+		return nil, fileMeta{}, cause
+	}
+	for _, b := range list {
+		raw, err := os.ReadFile(b.Path)
+		if err != nil {
+			continue
+		}
+		data, meta, err := s.decodeFile(raw, false)
+		if err != nil {
+			continue
+		}
+		if err := os.Rename(s.filePath, s.filePath+".corrupt"); err != nil {
+			return nil, meta, fmt.Errorf("kv: keep corrupt file: %w", err)
+		}
+		return data, meta, nil
+	}
+	return nil, fileMeta{}, cause
+}
@@ -0,0 +1,131 @@
+package kv_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+func TestKVStore_SnapshotRestore(t *testing.T) {
+	src, _ := kv.NewKVStore[string]("", kv.WithCodec(kv.GobCodec))
+	defer src.Close()
+	src.Set("a", "1")
+	src.Bucket("b").Set("x", "2")
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "restore.json")
+	dst, err := kv.NewKVStore[string](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst.Set("stale", "x")
+	ch, cancel := dst.Watch("")
+	defer cancel()
+
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dst.Get("stale"); ok {
+		t.Fatal("expected stale key removed by restore")
+	}
+	if v, _ := dst.Bucket("b").Get("x"); v != "2" {
+		t.Fatalf("expected bucket restored, got %q", v)
+	}
+	if ev := recv(t, ch); ev.Type != kv.EventDelete || ev.Key != "stale" {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	if ev := recv(t, ch); ev.Type != kv.EventSet || ev.Key != "a" {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	dst.Close()
+
+	// Restore 立即落盘（JSON，迁移自 gob）
+	reopened, err := kv.NewKVStore[string](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if v, _ := reopened.Get("a"); v != "1" {
+		t.Fatalf("expected restored data persisted, got %q", v)
+	}
+}
+
+func TestKVStore_RotatingBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+	store, err := kv.NewKVStore[int](path, kv.WithBackups(2), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	var marks []time.Time
+	for i := 1; i <= 3; i++ {
+		store.Set("n", i)
+		if err := store.Save(); err != nil {
+			t.Fatal(err)
+		}
+		marks = append(marks, time.Now())
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	list, err := store.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 backups kept, got %d", len(list))
+	}
+	if !list[0].Time.After(list[1].Time) {
+		t.Fatal("expected newest backup first")
+	}
+
+	// 回到第二次保存时的状态
+	if err := store.RestoreAt(marks[1]); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := store.Get("n"); v != 2 {
+		t.Fatalf("expected n=2 after point-in-time restore, got %d", v)
+	}
+	if err := store.RestoreAt(marks[0]); !errors.Is(err, kv.ErrNoBackup) {
+		t.Fatalf("expected ErrNoBackup for pruned generation, got %v", err)
+	}
+}
+
+func TestKVStore_CorruptFileFallsBackToBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+	store, err := kv.NewKVStore[string](path, kv.WithBackups(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set("k", "good")
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store2, err := kv.NewKVStore[string](path, kv.WithBackups(3))
+	if err != nil {
+		t.Fatalf("expected fallback to backup, got %v", err)
+	}
+	defer store2.Close()
+	if v, _ := store2.Get("k"); v != "good" {
+		t.Fatalf("expected value from backup, got %q", v)
+	}
+	if _, err := os.Stat(path + ".corrupt"); err != nil {
+		t.Fatalf("expected corrupt file kept: %v", err)
+	}
+}
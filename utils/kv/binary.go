@@ -0,0 +1,565 @@
+package kv
+
+import (
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// ---------------------------
+// 紧凑二进制格式（BinaryCodec 的实现）
+// ---------------------------
+//
+// 每个值以 1 字节类型标记开头，长度与整数均使用 varint：
+//   nil / bool / int(zigzag) / uint / float64 / string / bytes
+//   array: n + 元素
+//   map:   n + (key, value)
+//   struct: n + (字段名, value)，按名称匹配，字段增删不影响旧数据
+//   marshaler: 实现 encoding.BinaryMarshaler 或 gob.GobEncoder 的类型（time.Time、*big.Int 等）
+
+const (
+	binNil byte = iota
+	binFalse
+	binTrue
+	binInt
+	binUint
+	binFloat
+	binString
+	binBytes
+	binArray
+	binMap
+	binStruct
+	binMarshaler
+)
+
+var errBinaryCorrupt = errors.New("kv: corrupt binary data")
+
+var (
+	binaryMarshalerType   = reflect.TypeFor[encoding.BinaryMarshaler]()
+	binaryUnmarshalerType = reflect.TypeFor[encoding.BinaryUnmarshaler]()
+	gobEncoderType        = reflect.TypeFor[gob.GobEncoder]()
+	gobDecoderType        = reflect.TypeFor[gob.GobDecoder]()
+)
+
+func marshalBinary(v any) ([]byte, error) {
+	var e binEncoder
+	if err := e.encode(reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+func unmarshalBinary(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("kv: binary unmarshal requires non-nil pointer, got %T", v)
+	}
+	d := binDecoder{buf: data}
+	if err := d.decode(rv.Elem()); err != nil {
+		return err
+	}
+	if d.pos != len(d.buf) {
+		return errBinaryCorrupt
+	}
+	return nil
+}
+
+// ---------------------------
+// 编码
+// ---------------------------
+
+type binEncoder struct{ buf []byte }
+
+func (e *binEncoder) uvarint(x uint64) { e.buf = binary.AppendUvarint(e.buf, x) }
+
+func (e *binEncoder) raw(b []byte) {
+	e.uvarint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// marshaler 返回 v 自带的二进制编码（如有）
+func marshaler(v reflect.Value) (func() ([]byte, error), bool) {
+	t := v.Type()
+	if !t.Implements(binaryMarshalerType) && !t.Implements(gobEncoderType) {
+		// 指针接收者的方法需要可寻址的值
+		pt := reflect.PointerTo(t)
+		if !pt.Implements(binaryMarshalerType) && !pt.Implements(gobEncoderType) {
+			return nil, false
+		}
+		if !v.CanAddr() {
+			cp := reflect.New(t).Elem()
+			cp.Set(v)
+			v = cp
+		}
+		v = v.Addr()
+	}
+	switch m := v.Interface().(type) {
+	case encoding.BinaryMarshaler:
+		return m.MarshalBinary, true
+	case gob.GobEncoder:
+		return m.GobEncode, true
+	}
+	return nil, false
+}
+
+func (e *binEncoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf = append(e.buf, binNil)
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, binNil)
+			return nil
+		}
+	}
+	if v.Kind() != reflect.Interface {
+		if fn, ok := marshaler(v); ok {
+			b, err := fn()
+			if err != nil {
+				return err
+			}
+			e.buf = append(e.buf, binMarshaler)
+			e.raw(b)
+			return nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return e.encode(v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, binTrue)
+		} else {
+			e.buf = append(e.buf, binFalse)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.buf = append(e.buf, binInt)
+		e.buf = binary.AppendVarint(e.buf, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.buf = append(e.buf, binUint)
+		e.uvarint(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		e.buf = append(e.buf, binFloat)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v.Float()))
+	case reflect.String:
+		e.buf = append(e.buf, binString)
+		e.raw([]byte(v.String()))
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.buf = append(e.buf, binBytes)
+			if v.Kind() == reflect.Slice {
+				e.raw(v.Bytes())
+			} else {
+				b := make([]byte, v.Len())
+				reflect.Copy(reflect.ValueOf(b), v)
+				e.raw(b)
+			}
+			return nil
+		}
+		e.buf = append(e.buf, binArray)
+		e.uvarint(uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			if err := e.encode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		e.buf = append(e.buf, binMap)
+		e.uvarint(uint64(v.Len()))
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := e.encode(iter.Key()); err != nil {
+				return err
+			}
+			if err := e.encode(iter.Value()); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		n := 0
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				n++
+			}
+		}
+		e.buf = append(e.buf, binStruct)
+		e.uvarint(uint64(n))
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			e.raw([]byte(f.Name))
+			if err := e.encode(v.Field(i)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("kv: binary codec does not support %s", v.Type())
+	}
+	return nil
+}
+
+// ---------------------------
+// 解码
+// ---------------------------
+
+type binDecoder struct {
+	buf []byte
+	pos int
+}
+
+func (d *binDecoder) byte() (byte, error) {
+	if d.pos >= len(d.buf) {
+		return 0, errBinaryCorrupt
+	}
+	b := d.buf[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *binDecoder) uvarint() (uint64, error) {
+	x, n := binary.Uvarint(d.buf[d.pos:])
+	if n <= 0 {
+		return 0, errBinaryCorrupt
+	}
+	d.pos += n
+	return x, nil
+}
+
+func (d *binDecoder) varint() (int64, error) {
+	x, n := binary.Varint(d.buf[d.pos:])
+	if n <= 0 {
+		return 0, errBinaryCorrupt
+	}
+	d.pos += n
+	return x, nil
+}
+
+func (d *binDecoder) raw() ([]byte, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(d.buf)-d.pos) < n {
+		return nil, errBinaryCorrupt
+	}
+	b := d.buf[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// unmarshaler 返回 v 对应的自定义解码方法（如有）
+func unmarshaler(v reflect.Value) (func([]byte) error, bool) {
+	if !v.CanAddr() {
+		return nil, false
+	}
+	switch m := v.Addr().Interface().(type) {
+	case encoding.BinaryUnmarshaler:
+		return m.UnmarshalBinary, true
+	case gob.GobDecoder:
+		return m.GobDecode, true
+	}
+	return nil, false
+}
+
+func (d *binDecoder) decode(v reflect.Value) error {
+	tag, err := d.byte()
+	if err != nil {
+		return err
+	}
+
+	if tag == binNil {
+		v.SetZero()
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		d.pos--
+		return d.decode(v.Elem())
+	}
+	if v.Kind() == reflect.Interface {
+		if v.NumMethod() != 0 {
+			return fmt.Errorf("kv: binary codec cannot decode into %s", v.Type())
+		}
+		d.pos--
+		g, err := d.decodeAny()
+		if err != nil {
+			return err
+		}
+		if g == nil {
+			v.SetZero()
+		} else {
+			v.Set(reflect.ValueOf(g))
+		}
+		return nil
+	}
+
+	switch tag {
+	case binMarshaler:
+		b, err := d.raw()
+		if err != nil {
+			return err
+		}
+		fn, ok := unmarshaler(v)
+		if !ok {
+			return fmt.Errorf("kv: %s has no binary unmarshaler", v.Type())
+		}
+		return fn(append([]byte(nil), b...))
+	case binFalse, binTrue:
+		if v.Kind() != reflect.Bool {
+			return typeMismatch(tag, v)
+		}
+		v.SetBool(tag == binTrue)
+	case binInt:
+		x, err := d.varint()
+		if err != nil {
+			return err
+		}
+		return setInt(v, x)
+	case binUint:
+		x, err := d.uvarint()
+		if err != nil {
+			return err
+		}
+		return setUint(v, x)
+	case binFloat:
+		if len(d.buf)-d.pos < 8 {
+			return errBinaryCorrupt
+		}
+		f := math.Float64frombits(binary.BigEndian.Uint64(d.buf[d.pos:]))
+		d.pos += 8
+		if v.Kind() != reflect.Float32 && v.Kind() != reflect.Float64 {
+			return typeMismatch(tag, v)
+		}
+		v.SetFloat(f)
+	case binString:
+		b, err := d.raw()
+		if err != nil {
+			return err
+		}
+		if v.Kind() != reflect.String {
+			return typeMismatch(tag, v)
+		}
+		v.SetString(string(b))
+	case binBytes:
+		b, err := d.raw()
+		if err != nil {
+			return err
+		}
+		switch {
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			v.SetBytes(append([]byte(nil), b...))
+		case v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8:
+			reflect.Copy(v, reflect.ValueOf(b))
+		case v.Kind() == reflect.String:
+			v.SetString(string(b))
+		default:
+			return typeMismatch(tag, v)
+		}
+	case binArray:
+		n, err := d.uvarint()
+		if err != nil {
+			return err
+		}
+		switch v.Kind() {
+		case reflect.Slice:
+			if n > uint64(len(d.buf)-d.pos) {
+				return errBinaryCorrupt
+			}
+			v.Set(reflect.MakeSlice(v.Type(), int(n), int(n)))
+		case reflect.Array:
+			if n > uint64(v.Len()) {
+				return typeMismatch(tag, v)
+			}
+		default:
+			return typeMismatch(tag, v)
+		}
+		for i := 0; i < int(n); i++ {
+			if err := d.decode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case binMap:
+		n, err := d.uvarint()
+		if err != nil {
+			return err
+		}
+		if v.Kind() != reflect.Map {
+			return typeMismatch(tag, v)
+		}
+		if n > uint64(len(d.buf)-d.pos) {
+			return errBinaryCorrupt
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), int(n)))
+		}
+		kt, et := v.Type().Key(), v.Type().Elem()
+		for i := uint64(0); i < n; i++ {
+			k := reflect.New(kt).Elem()
+			if err := d.decode(k); err != nil {
+				return err
+			}
+			e := reflect.New(et).Elem()
+			if err := d.decode(e); err != nil {
+				return err
+			}
+			v.SetMapIndex(k, e)
+		}
+	case binStruct:
+		n, err := d.uvarint()
+		if err != nil {
+			return err
+		}
+		if v.Kind() != reflect.Struct {
+			return typeMismatch(tag, v)
+		}
+		for i := uint64(0); i < n; i++ {
+			name, err := d.raw()
+			if err != nil {
+				return err
+			}
+			f := v.FieldByName(string(name))
+			if !f.IsValid() || !f.CanSet() {
+				// 未知字段：跳过
+				if _, err := d.decodeAny(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.decode(f); err != nil {
+				return err
+			}
+		}
+	default:
+		return errBinaryCorrupt
+	}
+	return nil
+}
+
+// decodeAny 解码为通用 Go 值（用于 any 类型的目标或跳过未知字段）
+func (d *binDecoder) decodeAny() (any, error) {
+	tag, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+	switch tag {
+	case binNil:
+		return nil, nil
+	case binFalse:
+		return false, nil
+	case binTrue:
+		return true, nil
+	case binInt:
+		return d.varint()
+	case binUint:
+		return d.uvarint()
+	case binFloat:
+		if len(d.buf)-d.pos < 8 {
+			return nil, errBinaryCorrupt
+		}
+		f := math.Float64frombits(binary.BigEndian.Uint64(d.buf[d.pos:]))
+		d.pos += 8
+		return f, nil
+	case binString:
+		b, err := d.raw()
+		return string(b), err
+	case binBytes, binMarshaler:
+		b, err := d.raw()
+		return append([]byte(nil), b...), err
+	case binArray:
+		n, err := d.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if n > uint64(len(d.buf)-d.pos) {
+			return nil, errBinaryCorrupt
+		}
+		out := make([]any, n)
+		for i := range out {
+			if out[i], err = d.decodeAny(); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case binMap, binStruct:
+		n, err := d.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		out := make(map[string]any)
+		for i := uint64(0); i < n; i++ {
+			var k any
+			if tag == binStruct {
+				b, err := d.raw()
+				if err != nil {
+					return nil, err
+				}
+				k = string(b)
+			} else if k, err = d.decodeAny(); err != nil {
+				return nil, err
+			}
+			val, err := d.decodeAny()
+			if err != nil {
+				return nil, err
+			}
+			out[fmt.Sprint(k)] = val
+		}
+		return out, nil
+	}
+	return nil, errBinaryCorrupt
+}
+
+func setInt(v reflect.Value, x int64) error {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.OverflowInt(x) {
+			return fmt.Errorf("kv: value %d overflows %s", x, v.Type())
+		}
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if x < 0 || v.OverflowUint(uint64(x)) {
+			return fmt.Errorf("kv: value %d overflows %s", x, v.Type())
+		}
+		v.SetUint(uint64(x))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(x))
+	default:
+		return typeMismatch(binInt, v)
+	}
+	return nil
+}
+
+func setUint(v reflect.Value, x uint64) error {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.OverflowUint(x) {
+			return fmt.Errorf("kv: value %d overflows %s", x, v.Type())
+		}
+		v.SetUint(x)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if x > math.MaxInt64 || v.OverflowInt(int64(x)) {
+			return fmt.Errorf("kv: value %d overflows %s", x, v.Type())
+		}
+		v.SetInt(int64(x))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(x))
+	default:
+		return typeMismatch(binUint, v)
+	}
+	return nil
+}
+
+func typeMismatch(tag byte, v reflect.Value) error {
+	return fmt.Errorf("kv: binary tag %d cannot be decoded into %s", tag, v.Type())
+}
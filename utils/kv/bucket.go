@@ -0,0 +1,184 @@
+package kv
+
+import (
+	"iter"
+	"strings"
+	"time"
+)
+
+// ---------------------------
+// Bucket 命名空间
+// ---------------------------
+//
+// Bucket 与所属 KVStore 共享同一个持久化文件、后台循环与分片锁。
+// 内部以 "\x00<name>\x00<key>" 作为实际 key，根层面的 Keys / Scan / Range / Watch 不会看到这些键。
+// 因此以 "\x00" 开头的 key 是保留前缀，见 KVStore.Set。
+
+const bucketSep = "\x00"
+
+func isBucketKey(key string) bool {
+	return strings.HasPrefix(key, bucketSep)
+}
+
+// BucketKey 返回 Bucket 内 key 的内部形式，是 ParseBucketKey 的逆过程
+func BucketKey(bucket, key string) string {
+	return bucketSep + bucket + bucketSep + key
+}
+
+// ParseBucketKey 解析内部 key（如 OnEvict 回调收到的 key），ok 为 false 表示不属于任何 Bucket
+func ParseBucketKey(internal string) (bucket, key string, ok bool) {
+	if !isBucketKey(internal) {
+		return "", internal, false
+	}
+	bucket, key, ok = strings.Cut(internal[len(bucketSep):], bucketSep)
+	if !ok {
+		return "", internal, false
+	}
+	return bucket, key, true
+}
+
+// BucketOption 配置 Bucket
+type BucketOption func(*bucketConfig)
+
+type bucketConfig struct {
+	ttl time.Duration
+}
+
+// WithDefaultTTL 设置 Bucket.Set 使用的默认 ttl（默认 0 不过期）
+func WithDefaultTTL(ttl time.Duration) BucketOption {
+	return func(c *bucketConfig) { c.ttl = ttl }
+}
+
+// Bucket 独立 key 空间的视图
+type Bucket[V any] struct {
+	s      *KVStore[V]
+	name   string
+	prefix string
+	cfg    bucketConfig
+}
+
+// Bucket 返回名为 name 的 Bucket，同名多次调用返回同一个实例；传入 opts 时更新其配置。
+// name 不能为空且不能包含 NUL 字符。
+func (s *KVStore[V]) Bucket(name string, opts ...BucketOption) *Bucket[V] {
+	checkBucketName(name)
+	s.bucketsMu.Lock()
+	defer s.bucketsMu.Unlock()
+	if s.buckets == nil {
+		s.buckets = make(map[string]*Bucket[V])
+	}
+	b, ok := s.buckets[name]
+	if !ok {
+		b = &Bucket[V]{s: s, name: name, prefix: bucketSep + name + bucketSep}
+		s.buckets[name] = b
+	}
+	for _, o := range opts {
+		o(&b.cfg)
+	}
+	return b
+}
+
+// Name 返回 Bucket 名称
+func (b *Bucket[V]) Name() string { return b.name }
+
+func (b *Bucket[V]) key(k string) string { return b.prefix + k }
+
+// Set 使用默认 ttl 写入
+func (b *Bucket[V]) Set(key string, value V) {
+	b.s.SetWithTTL(b.key(key), value, b.cfg.ttl)
+}
+
+// SetWithTTL 使用指定 ttl 写入（零或负值表示不过期）
+func (b *Bucket[V]) SetWithTTL(key string, value V, ttl time.Duration) {
+	b.s.SetWithTTL(b.key(key), value, ttl)
+}
+
+// Get 获取键
+func (b *Bucket[V]) Get(key string) (V, bool) {
+	return b.s.Get(b.key(key))
+}
+
+// Delete 删除键
+func (b *Bucket[V]) Delete(key string) {
+	b.s.Delete(b.key(key))
+}
+
+// Exists 判断键是否存在且未过期
+func (b *Bucket[V]) Exists(key string) bool {
+	return b.s.Exists(b.key(key))
+}
+
+// TTL 同 KVStore.TTL
+func (b *Bucket[V]) TTL(key string) TTLResult {
+	return b.s.TTL(b.key(key))
+}
+
+// Keys 返回 Bucket 内所有未过期的键（顺序不保证）
+func (b *Bucket[V]) Keys() []string {
+	now := time.Now().UnixNano()
+	var keys []string
+	for _, sh := range b.s.shards {
+		sh.mu.RLock()
+		for k, it := range sh.data {
+			if !it.expired(now) && strings.HasPrefix(k, b.prefix) {
+				keys = append(keys, k[len(b.prefix):])
+			}
+		}
+		sh.mu.RUnlock()
+	}
+	return keys
+}
+
+// Len 返回 Bucket 内未过期的键数量
+func (b *Bucket[V]) Len() int {
+	now := time.Now().UnixNano()
+	n := 0
+	for _, sh := range b.s.shards {
+		sh.mu.RLock()
+		for k, it := range sh.data {
+			if !it.expired(now) && strings.HasPrefix(k, b.prefix) {
+				n++
+			}
+		}
+		sh.mu.RUnlock()
+	}
+	return n
+}
+
+// Clear 删除 Bucket 内的所有键
+func (b *Bucket[V]) Clear() {
+	for _, sh := range b.s.shards {
+		sh.mu.Lock()
+		for k := range sh.data {
+			if strings.HasPrefix(k, b.prefix) {
+				b.s.removeLocked(sh, k, EventDelete)
+			}
+		}
+		b.s.unlock(sh, "")
+	}
+}
+
+// Scan 同 KVStore.Scan，key 不含 Bucket 前缀
+func (b *Bucket[V]) Scan(prefix string, opts ...ScanOption) iter.Seq2[string, V] {
+	full := b.key(prefix)
+	return b.s.scan(func(k string) bool { return strings.HasPrefix(k, full) }, len(b.prefix), opts)
+}
+
+// ScanBucket 同 Bucket(name).Scan，但不创建（也不保留）Bucket 实例，
+// 适合按外部输入的名称临时遍历（如管理接口）。名称无效时 panic，与 Bucket 相同。
+func (s *KVStore[V]) ScanBucket(name, prefix string, opts ...ScanOption) iter.Seq2[string, V] {
+	checkBucketName(name)
+	base := bucketSep + name + bucketSep
+	full := base + prefix
+	return s.scan(func(k string) bool { return strings.HasPrefix(k, full) }, len(base), opts)
+}
+
+func checkBucketName(name string) {
+	if name == "" || strings.Contains(name, bucketSep) {
+		panic("kv: invalid bucket name " + `"` + name + `"`)
+	}
+}
+
+// Watch 订阅 Bucket 内 key 以 prefix 开头的变更，事件中的 key 不含 Bucket 前缀
+func (b *Bucket[V]) Watch(prefix string) (<-chan Event[V], func()) {
+	return b.s.subscribe(b.key(prefix), len(b.prefix))
+}
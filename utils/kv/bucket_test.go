@@ -0,0 +1,127 @@
+package kv_test
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+func TestKVStore_Buckets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "buckets.json")
+	store, err := kv.NewKVStore[string](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions := store.Bucket("sessions", kv.WithDefaultTTL(time.Hour))
+	flags := store.Bucket("flags")
+
+	store.Set("k", "root")
+	sessions.Set("k", "session")
+	flags.Set("k", "flag")
+	flags.Set("k2", "flag2")
+
+	if v, _ := store.Get("k"); v != "root" {
+		t.Fatalf("expected root value, got %q", v)
+	}
+	if v, _ := sessions.Get("k"); v != "session" {
+		t.Fatalf("expected session value, got %q", v)
+	}
+	if ttl := sessions.TTL("k"); ttl.State != kv.TTLExpiring || ttl.Remaining <= 0 {
+		t.Fatalf("expected default ttl applied, got %+v", ttl)
+	}
+	if ttl := flags.TTL("k"); ttl.State != kv.TTLNoExpiry {
+		t.Fatalf("expected no ttl in flags, got %+v", ttl)
+	}
+	if keys := store.Keys(); len(keys) != 1 || keys[0] != "k" {
+		t.Fatalf("expected root keys isolated, got %q", keys)
+	}
+	if n := flags.Len(); n != 2 {
+		t.Fatalf("expected 2 flags, got %d", n)
+	}
+	if store.Bucket("flags") != flags {
+		t.Fatal("expected same bucket instance")
+	}
+
+	flags.Clear()
+	if n := flags.Len(); n != 0 {
+		t.Fatalf("expected flags cleared, got %d", n)
+	}
+	if !sessions.Exists("k") {
+		t.Fatal("expected sessions untouched by flags.Clear")
+	}
+	store.Close()
+
+	// 共享同一个文件
+	store2, err := kv.NewKVStore[string](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store2.Close()
+	if v, _ := store2.Bucket("sessions").Get("k"); v != "session" {
+		t.Fatalf("expected bucket persisted, got %q", v)
+	}
+}
+
+func TestKVStore_BucketScanWatch(t *testing.T) {
+	store, err := kv.NewKVStore[int]("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	b := store.Bucket("b")
+	rootCh, cancelRoot := store.Watch("")
+	defer cancelRoot()
+	ch, cancel := b.Watch("x")
+	defer cancel()
+
+	b.Set("x1", 1)
+	b.Set("y1", 2)
+	b.Set("x2", 3)
+
+	var keys []string
+	for k := range b.Scan("x") {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "x1" || keys[1] != "x2" {
+		t.Fatalf("unexpected bucket scan %q", keys)
+	}
+
+	if ev := recv(t, ch); ev.Key != "x1" {
+		t.Fatalf("expected trimmed key x1, got %q", ev.Key)
+	}
+	if ev := recv(t, ch); ev.Key != "x2" {
+		t.Fatalf("expected trimmed key x2, got %q", ev.Key)
+	}
+	select {
+	case ev := <-rootCh:
+		t.Fatalf("root watcher should not see bucket keys, got %+v", ev)
+	default:
+	}
+}
+
+func TestKVStore_ReservedRootPrefix(t *testing.T) {
+	store, err := kv.NewKVStore[string]("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	// NUL 开头的根 key 属于 Bucket 的保留前缀：BucketKey 形式写入对应 Bucket，根视图看不到
+	store.Set(kv.BucketKey("sessions", "u1"), "v")
+	store.Set("\x00orphan", "w")
+	if v, ok := store.Bucket("sessions").Get("u1"); !ok || v != "v" {
+		t.Fatalf("expected write to land in bucket, got %q %v", v, ok)
+	}
+	if keys := store.Keys(); len(keys) != 0 {
+		t.Fatalf("expected reserved keys hidden from root, got %q", keys)
+	}
+	if v, ok := store.Get("\x00orphan"); !ok || v != "w" {
+		t.Fatalf("expected reserved key readable by exact key, got %q %v", v, ok)
+	}
+}
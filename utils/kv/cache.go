@@ -0,0 +1,204 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// ---------------------------
+// Cache：读穿透加载缓存
+// ---------------------------
+//
+// Cache 建立在 KVStore[CacheEntry[V]] 之上：未命中时调用 loader 加载并写回，
+// 同一个键的并发加载只执行一次（singleflight）。可选：
+//   - 负缓存：loader 返回 ErrNotFound 时缓存“不存在”，期间不再调用 loader
+//   - 抖动：实际 ttl 在配置值上随机增加最多 jitter 比例，避免同批写入同时过期
+//   - 过期后仍可用（stale-while-revalidate）：新鲜期过后的一段时间内直接返回旧值，并在后台刷新
+
+// ErrNotFound loader 用它表示数据不存在（开启负缓存时会被缓存）
+var ErrNotFound = errors.New("kv: not found")
+
+// Loader 从数据源加载 key 对应的值
+type Loader[V any] func(ctx context.Context, key string) (V, error)
+
+// CacheEntry Cache 在 KVStore 中保存的条目
+type CacheEntry[V any] struct {
+	Value      V     `json:"value"`
+	Missing    bool  `json:"missing,omitempty"`     // 负缓存条目
+	FreshUntil int64 `json:"fresh_until,omitempty"` // 新鲜期截止（UnixNano），之后到过期前为 stale
+}
+
+// CacheOption 配置 Cache
+type CacheOption func(*cacheConfig)
+
+type cacheConfig struct {
+	ttl         time.Duration
+	negativeTTL time.Duration
+	jitter      float64
+	stale       time.Duration
+	loadTimeout time.Duration
+}
+
+// WithCacheTTL 设置加载结果的新鲜期（默认 5m）
+func WithCacheTTL(d time.Duration) CacheOption {
+	return func(c *cacheConfig) { c.ttl = d }
+}
+
+// WithNegativeTTL 开启负缓存：loader 返回 ErrNotFound 时缓存 d（默认 0 不缓存）
+func WithNegativeTTL(d time.Duration) CacheOption {
+	return func(c *cacheConfig) { c.negativeTTL = d }
+}
+
+// WithJitter 为 ttl 随机增加 [0, frac) 比例的时长（默认 0，frac 取值 0 ~ 1）
+func WithJitter(frac float64) CacheOption {
+	return func(c *cacheConfig) { c.jitter = min(max(frac, 0), 1) }
+}
+
+// WithStaleWhileRevalidate 新鲜期过后的 d 内仍返回旧值，同时在后台刷新（默认 0 关闭）
+func WithStaleWhileRevalidate(d time.Duration) CacheOption {
+	return func(c *cacheConfig) { c.stale = d }
+}
+
+// WithLoadTimeout 设置单次加载的超时（默认 0 不限制）。
+// 加载与调用方的 ctx 取消解耦：调用方取消只会使自己提前返回，不影响其它等待者。
+func WithLoadTimeout(d time.Duration) CacheOption {
+	return func(c *cacheConfig) { c.loadTimeout = d }
+}
+
+// Cache 读穿透加载缓存
+type Cache[V any] struct {
+	store *KVStore[CacheEntry[V]]
+	cfg   cacheConfig
+
+	mu     sync.Mutex
+	flight map[string]*loadCall[V]
+}
+
+type loadCall[V any] struct {
+	done chan struct{}
+	val  V
+	err  error
+}
+
+// NewCache 基于 store 创建 Cache，store 的生命周期由调用方管理
+func NewCache[V any](store *KVStore[CacheEntry[V]], opts ...CacheOption) *Cache[V] {
+	cfg := cacheConfig{ttl: 5 * time.Minute}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	return &Cache[V]{store: store, cfg: cfg, flight: make(map[string]*loadCall[V])}
+}
+
+// Store 返回底层 KVStore
+func (c *Cache[V]) Store() *KVStore[CacheEntry[V]] { return c.store }
+
+// GetOrLoad 返回缓存的值；未命中时调用 loader 加载并缓存，同一个键的并发调用共享一次加载。
+// 负缓存命中时返回 ErrNotFound；处于 stale 期时返回旧值并在后台刷新。
+func (c *Cache[V]) GetOrLoad(ctx context.Context, key string, loader Loader[V]) (V, error) {
+	if e, ok := c.store.Get(key); ok {
+		if e.FreshUntil > 0 && time.Now().UnixNano() > e.FreshUntil {
+			c.load(ctx, key, loader)
+		}
+		if e.Missing {
+			var zero V
+			return zero, ErrNotFound
+		}
+		return e.Value, nil
+	}
+
+	call := c.load(ctx, key, loader)
+	select {
+	case <-call.done:
+		return call.val, call.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// Get 只读缓存（含 stale 值），不触发加载；负缓存条目视为不存在
+func (c *Cache[V]) Get(key string) (V, bool) {
+	e, ok := c.store.Get(key)
+	if !ok || e.Missing {
+		var zero V
+		return zero, false
+	}
+	return e.Value, true
+}
+
+// Set 直接写入缓存，使用与加载结果相同的 ttl
+func (c *Cache[V]) Set(key string, value V) {
+	c.put(key, CacheEntry[V]{Value: value}, c.cfg.ttl)
+}
+
+// Invalidate 删除缓存（含负缓存），下次访问重新加载
+func (c *Cache[V]) Invalidate(key string) {
+	c.store.Delete(key)
+}
+
+// load 发起（或加入）key 的加载，返回对应的调用
+func (c *Cache[V]) load(ctx context.Context, key string, loader Loader[V]) *loadCall[V] {
+	c.mu.Lock()
+	if call, ok := c.flight[key]; ok {
+		c.mu.Unlock()
+		return call
+	}
+	call := &loadCall[V]{done: make(chan struct{})}
+	c.flight[key] = call
+	c.mu.Unlock()
+
+	lctx := context.WithoutCancel(ctx)
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.flight, key)
+			c.mu.Unlock()
+			close(call.done)
+		}()
+		var cancel context.CancelFunc = func() {}
+		if c.cfg.loadTimeout > 0 {
+			lctx, cancel = context.WithTimeout(lctx, c.cfg.loadTimeout)
+		}
+		defer cancel()
+
+		call.val, call.err = c.call(lctx, key, loader)
+		switch {
+		case call.err == nil:
+			c.put(key, CacheEntry[V]{Value: call.val}, c.cfg.ttl)
+		case errors.Is(call.err, ErrNotFound) && c.cfg.negativeTTL > 0:
+			c.put(key, CacheEntry[V]{Missing: true}, c.cfg.negativeTTL)
+		}
+		// 其它错误不缓存；stale 值保留到过期
+	}()
+	return call
+}
+
+// call 执行 loader，panic 作为本次加载的错误返回给所有等待方
+func (c *Cache[V]) call(ctx context.Context, key string, loader Loader[V]) (v V, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("kv: loader panic: %v", r)
+		}
+	}()
+	return loader(ctx, key)
+}
+
+// put 按抖动后的 ttl 写入，开启 stale-while-revalidate 时额外保留 stale 窗口
+func (c *Cache[V]) put(key string, e CacheEntry[V], ttl time.Duration) {
+	if ttl <= 0 {
+		c.store.Set(key, e)
+		return
+	}
+	if c.cfg.jitter > 0 {
+		ttl += time.Duration(rand.Int64N(int64(float64(ttl)*c.cfg.jitter) + 1))
+	}
+	if c.cfg.stale > 0 {
+		e.FreshUntil = time.Now().Add(ttl).UnixNano()
+		ttl += c.cfg.stale
+	}
+	c.store.SetWithTTL(key, e, ttl)
+}
@@ -0,0 +1,161 @@
+package kv_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+func newCache[V any](t *testing.T, opts ...kv.CacheOption) *kv.Cache[V] {
+	t.Helper()
+	store, err := kv.NewKVStore[kv.CacheEntry[V]]("", kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return kv.NewCache(store, opts...)
+}
+
+func TestCache_SingleFlight(t *testing.T) {
+	cache := newCache[string](t)
+	var calls atomic.Int32
+	loader := func(ctx context.Context, key string) (string, error) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return "v:" + key, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := cache.GetOrLoad(context.Background(), "k", loader)
+			if err != nil || v != "v:k" {
+				t.Errorf("unexpected %q %v", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected 1 load, got %d", n)
+	}
+	if v, ok := cache.Get("k"); !ok || v != "v:k" {
+		t.Fatalf("expected cached value, got %q %v", v, ok)
+	}
+}
+
+func TestCache_NegativeCaching(t *testing.T) {
+	cache := newCache[int](t, kv.WithNegativeTTL(time.Minute))
+	var calls atomic.Int32
+	loader := func(ctx context.Context, key string) (int, error) {
+		calls.Add(1)
+		return 0, kv.ErrNotFound
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := cache.GetOrLoad(context.Background(), "missing", loader); !errors.Is(err, kv.ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected negative result cached, got %d loads", n)
+	}
+
+	// 其它错误不缓存
+	boom := errors.New("boom")
+	failing := func(ctx context.Context, key string) (int, error) { calls.Add(1); return 0, boom }
+	cache.GetOrLoad(context.Background(), "err", failing)
+	if _, err := cache.GetOrLoad(context.Background(), "err", failing); !errors.Is(err, boom) {
+		t.Fatalf("expected boom, got %v", err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("expected errors not cached, got %d loads", n)
+	}
+}
+
+func TestCache_StaleWhileRevalidate(t *testing.T) {
+	cache := newCache[int](t, kv.WithCacheTTL(20*time.Millisecond), kv.WithStaleWhileRevalidate(time.Minute))
+	var version atomic.Int32
+	loader := func(ctx context.Context, key string) (int, error) {
+		return int(version.Add(1)), nil
+	}
+
+	if v, _ := cache.GetOrLoad(context.Background(), "k", loader); v != 1 {
+		t.Fatalf("expected 1, got %d", v)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	// stale：立即返回旧值，后台刷新
+	if v, _ := cache.GetOrLoad(context.Background(), "k", loader); v != 1 {
+		t.Fatalf("expected stale value 1, got %d", v)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		if v, _ := cache.Get("k"); v == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected background refresh")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCache_CallerCancel(t *testing.T) {
+	cache := newCache[string](t)
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) (string, error) {
+		<-release
+		return "late", nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := cache.GetOrLoad(ctx, "k", loader); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	// 调用方取消不影响进行中的加载
+	done := make(chan string)
+	go func() {
+		v, _ := cache.GetOrLoad(context.Background(), "k", loader)
+		done <- v
+	}()
+	close(release)
+	if v := <-done; v != "late" {
+		t.Fatalf("expected shared load result, got %q", v)
+	}
+}
+
+func TestCache_Jitter(t *testing.T) {
+	cache := newCache[int](t, kv.WithCacheTTL(time.Minute), kv.WithJitter(0.5))
+	for _, k := range []string{"a", "b", "c", "d"} {
+		cache.Set(k, 1)
+		r := cache.Store().TTL(k)
+		if r.Remaining > 90*time.Second || r.Remaining < 59*time.Second {
+			t.Fatalf("ttl %v outside jitter range", r.Remaining)
+		}
+	}
+}
+
+func TestCache_LoaderPanic(t *testing.T) {
+	cache := newCache[string](t)
+	loader := func(ctx context.Context, key string) (string, error) {
+		panic("boom")
+	}
+	if _, err := cache.GetOrLoad(context.Background(), "k", loader); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected panic returned as error, got %v", err)
+	}
+
+	// panic 不缓存，下一次重新加载
+	ok := func(ctx context.Context, key string) (string, error) { return "v", nil }
+	if v, err := cache.GetOrLoad(context.Background(), "k", ok); err != nil || v != "v" {
+		t.Fatalf("expected reload after panic, got %q %v", v, err)
+	}
+}
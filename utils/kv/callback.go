@@ -0,0 +1,68 @@
+package kv
+
+// ---------------------------
+// 淘汰 / 过期回调
+// ---------------------------
+
+// EvictReason 条目离开存储的原因
+type EvictReason int
+
+const (
+	EvictExpired  EvictReason = iota // 过期（后台清理或 Get 时惰性删除）
+	EvictDeleted                     // 主动删除（含 Restore / 重新加载时被移除）
+	EvictReplaced                    // 被新值覆盖
+	EvictCapacity                    // 因容量限制被淘汰
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictExpired:
+		return "expired"
+	case EvictDeleted:
+		return "deleted"
+	case EvictReplaced:
+		return "replaced"
+	case EvictCapacity:
+		return "capacity"
+	}
+	return "unknown"
+}
+
+// OnEvict 注册条目离开存储时的回调（再次调用会替换，传 nil 取消）。
+// 回调在释放存储锁之后、由触发变更的 goroutine 同步调用，可以安全调用 KVStore；
+// Bucket 内的键以内部形式传入，可用 ParseBucketKey 解析。
+func (s *KVStore[V]) OnEvict(fn func(key string, value V, reason EvictReason)) {
+	if fn == nil {
+		s.watch.onEvict.Store(nil)
+		return
+	}
+	s.watch.onEvict.Store(&fn)
+}
+
+// evictReason 把事件映射为回调原因；新键的写入与重新加载通知不触发回调
+func evictReason[V any](ev Event[V]) (EvictReason, bool) {
+	switch ev.Type {
+	case EventSet:
+		return EvictReplaced, ev.HasOld
+	case EventDelete:
+		return EvictDeleted, true
+	case EventExpire:
+		return EvictExpired, true
+	case EventEvict:
+		return EvictCapacity, true
+	}
+	return 0, false
+}
+
+// notify 依次调用回调（调用方不能持有任何存储锁）
+func (h *hub[V]) notify(events []Event[V]) {
+	fn := h.onEvict.Load()
+	if fn == nil {
+		return
+	}
+	for _, ev := range events {
+		if reason, ok := evictReason(ev); ok {
+			(*fn)(ev.Key, ev.Old, reason)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package kv_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+type evicted struct {
+	key    string
+	value  int
+	reason kv.EvictReason
+}
+
+func collectEvictions(store *kv.KVStore[int]) func() []evicted {
+	var (
+		mu   sync.Mutex
+		list []evicted
+	)
+	store.OnEvict(func(key string, value int, reason kv.EvictReason) {
+		mu.Lock()
+		list = append(list, evicted{key, value, reason})
+		mu.Unlock()
+	})
+	return func() []evicted {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]evicted(nil), list...)
+	}
+}
+
+func TestKVStore_OnEvictReasons(t *testing.T) {
+	store, _ := kv.NewKVStore[int]("", kv.WithSaveInterval(0), kv.WithMaxEntries(2))
+	defer store.Close()
+	got := collectEvictions(store)
+
+	store.Set("a", 1)
+	store.Set("a", 2)                            // replaced
+	store.Delete("a")                            // deleted
+	store.SetWithTTL("t", 3, 5*time.Millisecond) // expired（Get 惰性删除）
+	time.Sleep(10 * time.Millisecond)
+	if _, ok := store.Get("t"); ok {
+		t.Fatal("expected t expired")
+	}
+	store.Set("x", 4)
+	store.Set("y", 5)
+	store.Set("z", 6) // capacity：淘汰 x
+
+	want := []evicted{
+		{"a", 1, kv.EvictReplaced},
+		{"a", 2, kv.EvictDeleted},
+		{"t", 3, kv.EvictExpired},
+		{"x", 4, kv.EvictCapacity},
+	}
+	list := got()
+	if len(list) != len(want) {
+		t.Fatalf("expected %d callbacks, got %+v", len(want), list)
+	}
+	for i := range want {
+		if list[i] != want[i] {
+			t.Fatalf("callback %d: expected %+v, got %+v", i, want[i], list[i])
+		}
+	}
+	if store.Exists("t") {
+		t.Fatal("expected lazily expired key removed")
+	}
+}
+
+func TestKVStore_OnEvictFromCleanup(t *testing.T) {
+	store, _ := kv.NewKVStore[int]("", kv.WithSaveInterval(0), kv.WithCleanupInterval(5*time.Millisecond))
+	defer store.Close()
+
+	done := make(chan evicted, 1)
+	store.OnEvict(func(key string, value int, reason kv.EvictReason) {
+		// 回调在锁外执行，可以再次写入
+		store.Set("audit:"+key, value)
+		done <- evicted{key, value, reason}
+	})
+	store.SetWithTTL("session", 7, 10*time.Millisecond)
+
+	select {
+	case ev := <-done:
+		if ev != (evicted{"session", 7, kv.EvictExpired}) {
+			t.Fatalf("unexpected callback %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected expiry callback from cleanup")
+	}
+	if v, ok := store.Get("audit:session"); !ok || v != 7 {
+		t.Fatalf("expected write from callback, got %v %v", v, ok)
+	}
+}
+
+func TestParseBucketKey(t *testing.T) {
+	store, _ := kv.NewKVStore[int]("", kv.WithSaveInterval(0))
+	defer store.Close()
+	var key string
+	store.OnEvict(func(k string, _ int, _ kv.EvictReason) { key = k })
+	store.Bucket("sessions").Set("u1", 1)
+	store.Bucket("sessions").Delete("u1")
+
+	bucket, k, ok := kv.ParseBucketKey(key)
+	if !ok || bucket != "sessions" || k != "u1" {
+		t.Fatalf("unexpected parse %q %q %v", bucket, k, ok)
+	}
+	if _, _, ok := kv.ParseBucketKey("plain"); ok {
+		t.Fatal("expected plain key not in bucket")
+	}
+}
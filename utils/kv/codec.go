@@ -0,0 +1,173 @@
+package kv
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ---------------------------
+// Codec 磁盘编码
+// ---------------------------
+
+// Codec 决定快照与 WAL 记录的编码方式。
+// Name 会写入文件头，用于 load 时识别文件由哪个 Codec 写出。
+type Codec interface {
+	Name() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	// JSONCodec encoding/json 编码（默认）。为保持可读与兼容旧文件，JSON 文件不写文件头。
+	JSONCodec Codec = jsonCodec{}
+	// GobCodec encoding/gob 编码，V 中包含接口类型时需要先 gob.Register
+	GobCodec Codec = gobCodec{}
+	// BinaryCodec 紧凑二进制编码，保留 []byte、time.Time、*big.Int 等类型的精度
+	BinaryCodec Codec = binaryCodec{}
+)
+
+var (
+	// ErrCodecMismatch 文件的 Codec 与配置不一致（仅在 WithStrictCodec(true) 时返回）
+	ErrCodecMismatch = errors.New("kv: codec mismatch")
+	// ErrUnknownCodec 文件头中的 Codec 未注册
+	ErrUnknownCodec = errors.New("kv: unknown codec")
+)
+
+// WithCodec 设置持久化编码（默认 JSONCodec）
+func WithCodec(c Codec) Option {
+	return func(cfg *config) { cfg.codec = c }
+}
+
+// WithStrictCodec 为 true 时，文件 Codec 与配置不一致直接报错；
+// 默认 false：按文件头自动识别读取，下一次保存时迁移为配置的 Codec。
+func WithStrictCodec(strict bool) Option {
+	return func(cfg *config) { cfg.strictCodec = strict }
+}
+
+var (
+	codecMu  sync.RWMutex
+	registry = map[string]Codec{}
+)
+
+// RegisterCodec 注册自定义 Codec，使 load 能按文件头识别。同名覆盖。
+func RegisterCodec(c Codec) {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	registry[c.Name()] = c
+}
+
+func lookupCodec(name string) (Codec, bool) {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	c, ok := registry[name]
+	return c, ok
+}
+
+func init() {
+	RegisterCodec(JSONCodec)
+	RegisterCodec(GobCodec)
+	RegisterCodec(BinaryCodec)
+}
+
+// ---------------------------
+// 文件头：magic(4) + nameLen(1) + name
+// ---------------------------
+
+const headerMagic = "GKV\x01"
+
+func encodeHeader(c Codec) []byte {
+	name := c.Name()
+	h := make([]byte, 0, len(headerMagic)+1+len(name))
+	h = append(h, headerMagic...)
+	h = append(h, byte(len(name)))
+	return append(h, name...)
+}
+
+// splitHeader 解析文件头；无文件头时 ok = false
+func splitHeader(data []byte) (name string, body []byte, ok bool) {
+	if !bytes.HasPrefix(data, []byte(headerMagic)) || len(data) < len(headerMagic)+1 {
+		return "", data, false
+	}
+	n := int(data[len(headerMagic)])
+	start := len(headerMagic) + 1
+	if len(data) < start+n {
+		return "", data, false
+	}
+	return string(data[start : start+n]), data[start+n:], true
+}
+
+// encodeWithHeader 编码 v 并加上文件头（JSON 不加）
+func encodeWithHeader(c Codec, v any) ([]byte, error) {
+	body, err := c.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if c.Name() == JSONCodec.Name() {
+		return body, nil
+	}
+	return append(encodeHeader(c), body...), nil
+}
+
+// detectCodec 根据文件头识别 Codec，返回去掉文件头的内容
+func detectCodec(data []byte, want Codec, strict bool) (Codec, []byte, error) {
+	name, body, ok := splitHeader(data)
+	if !ok {
+		name = JSONCodec.Name()
+	}
+	if strict && name != want.Name() {
+		return nil, nil, fmt.Errorf("%w: file written by %q, configured %q", ErrCodecMismatch, name, want.Name())
+	}
+	if name == want.Name() {
+		return want, body, nil
+	}
+	c, found := lookupCodec(name)
+	if !found {
+		return nil, nil, fmt.Errorf("%w: %q", ErrUnknownCodec, name)
+	}
+	return c, body, nil
+}
+
+// ---------------------------
+// 内置实现
+// ---------------------------
+
+type jsonCodec struct{ indent bool }
+
+func (jsonCodec) Name() string { return "json" }
+
+func (c jsonCodec) Marshal(v any) ([]byte, error) {
+	if c.indent {
+		return json.MarshalIndent(v, "", "  ")
+	}
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type binaryCodec struct{}
+
+func (binaryCodec) Name() string { return "binary" }
+
+func (binaryCodec) Marshal(v any) ([]byte, error) { return marshalBinary(v) }
+
+func (binaryCodec) Unmarshal(data []byte, v any) error { return unmarshalBinary(data, v) }
@@ -0,0 +1,132 @@
+package kv_test
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+type codecValue struct {
+	Name    string
+	Raw     []byte
+	At      time.Time
+	Big     *big.Int
+	Counts  map[string]int
+	Ratio   float64
+	Enabled bool
+}
+
+func TestKVStore_Codecs(t *testing.T) {
+	at := time.Date(2024, 5, 6, 7, 8, 9, 10, time.FixedZone("X", 3600))
+	n, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	want := codecValue{
+		Name:    "v",
+		Raw:     []byte{0, 1, 2, 255},
+		At:      at,
+		Big:     n,
+		Counts:  map[string]int{"a": -1, "b": 2},
+		Ratio:   0.25,
+		Enabled: true,
+	}
+
+	for _, c := range []kv.Codec{kv.JSONCodec, kv.GobCodec, kv.BinaryCodec} {
+		t.Run(c.Name(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "codec.db")
+
+			store, err := kv.NewKVStore[codecValue](path, kv.WithCodec(c))
+			if err != nil {
+				t.Fatal(err)
+			}
+			store.SetWithTTL("k", want, time.Hour)
+			if err := store.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			store2, err := kv.NewKVStore[codecValue](path, kv.WithCodec(c), kv.WithStrictCodec(true))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store2.Close()
+
+			got, ok := store2.Get("k")
+			if !ok {
+				t.Fatal("expected k after reload")
+			}
+			if got.Name != want.Name || string(got.Raw) != string(want.Raw) || !got.At.Equal(want.At) ||
+				got.Big.Cmp(want.Big) != 0 || got.Counts["a"] != -1 || got.Ratio != want.Ratio || !got.Enabled {
+				t.Fatalf("round trip mismatch: %+v", got)
+			}
+			if ttl := store2.TTL("k"); ttl.State != kv.TTLExpiring || ttl.Remaining <= 0 {
+				t.Fatalf("expected ttl kept, got %+v", ttl)
+			}
+		})
+	}
+}
+
+func TestKVStore_CodecMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "codec.db")
+
+	store, err := kv.NewKVStore[string](path, kv.WithCodec(kv.GobCodec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set("a", "1")
+	store.Close()
+
+	if _, err := kv.NewKVStore[string](path, kv.WithCodec(kv.BinaryCodec), kv.WithStrictCodec(true)); !errors.Is(err, kv.ErrCodecMismatch) {
+		t.Fatalf("expected ErrCodecMismatch, got %v", err)
+	}
+
+	// 非严格模式自动识别，并在保存时迁移为 JSON
+	store2, err := kv.NewKVStore[string](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := store2.Get("a"); v != "1" {
+		t.Fatalf("expected auto-detected value, got %q", v)
+	}
+	store2.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0] != '{' {
+		t.Fatalf("expected file migrated to json, got %q", data)
+	}
+}
+
+func TestKVStore_WALCodecSwitch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "codec.db")
+
+	store, err := kv.NewKVStore[string](path, kv.WithWAL(true), kv.WithCodec(kv.BinaryCodec), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set("a", "1")
+
+	// 使用另一个 Codec 重新打开：旧日志按其文件头重放
+	store2, err := kv.NewKVStore[string](path, kv.WithWAL(true), kv.WithCodec(kv.GobCodec), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store2.Set("b", "2")
+
+	store3, err := kv.NewKVStore[string](path, kv.WithWAL(true), kv.WithCodec(kv.GobCodec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store3.Close()
+
+	if v, _ := store3.Get("a"); v != "1" {
+		t.Fatalf("expected a=1, got %q", v)
+	}
+	if v, _ := store3.Get("b"); v != "2" {
+		t.Fatalf("expected b=2, got %q", v)
+	}
+}
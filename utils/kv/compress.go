@@ -0,0 +1,114 @@
+package kv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ---------------------------
+// 压缩
+// ---------------------------
+//
+// 保存时按 编码 → 压缩 → 加密 的顺序处理快照，load 时逆序进行，
+// 压缩格式按内容开头的魔数识别，因此未压缩的旧文件可以直接读取并在下次保存时迁移。
+// WAL 记录不压缩。
+
+// Compressor 快照压缩算法。Magic 为压缩后内容固定的开头字节，用于 load 时识别。
+type Compressor interface {
+	Name() string
+	Magic() []byte
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// GzipCompressor compress/gzip 默认压缩级别
+var GzipCompressor Compressor = gzipCompressor{level: gzip.DefaultCompression}
+
+// NewGzipCompressor 返回指定压缩级别（gzip.BestSpeed ~ gzip.BestCompression）的 gzip Compressor
+func NewGzipCompressor(level int) (Compressor, error) {
+	if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil {
+		return nil, err
+	}
+	return gzipCompressor{level: level}, nil
+}
+
+// WithCompression 保存时压缩快照与备份（默认不压缩）
+func WithCompression(c Compressor) Option {
+	return func(cfg *config) { cfg.compressor = c }
+}
+
+var (
+	compressorMu sync.RWMutex
+	compressors  = map[string]Compressor{}
+)
+
+// RegisterCompressor 注册自定义 Compressor，使 load 能按魔数识别。同名覆盖。
+func RegisterCompressor(c Compressor) {
+	if len(c.Magic()) == 0 {
+		panic(fmt.Sprintf("kv: compressor %q has empty magic", c.Name()))
+	}
+	compressorMu.Lock()
+	defer compressorMu.Unlock()
+	compressors[c.Name()] = c
+}
+
+// detectCompressor 按魔数识别压缩格式，未压缩时返回 nil
+func detectCompressor(data []byte) Compressor {
+	compressorMu.RLock()
+	defer compressorMu.RUnlock()
+	for _, c := range compressors {
+		if bytes.HasPrefix(data, c.Magic()) {
+			return c
+		}
+	}
+	return nil
+}
+
+func init() {
+	RegisterCompressor(GzipCompressor)
+}
+
+// compressorName 返回 c 的名称，nil 为空字符串
+func compressorName(c Compressor) string {
+	if c == nil {
+		return ""
+	}
+	return c.Name()
+}
+
+// ---------------------------
+// gzip
+// ---------------------------
+
+type gzipCompressor struct{ level int }
+
+func (gzipCompressor) Name() string { return "gzip" }
+
+func (gzipCompressor) Magic() []byte { return []byte{0x1f, 0x8b} }
+
+func (c gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
@@ -0,0 +1,79 @@
+package kv_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+func TestKVStore_GzipMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.json")
+
+	plain, _ := kv.NewKVStore[string](path)
+	for i := 0; i < 500; i++ {
+		plain.Set("key-"+strconv.Itoa(i), strings.Repeat("value ", 20))
+	}
+	plain.Close()
+	before, _ := os.ReadFile(path)
+
+	// 未压缩的旧文件自动识别，并在下次保存时压缩
+	store, err := kv.NewKVStore[string](path, kv.WithCompression(kv.GzipCompressor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	after, _ := os.ReadFile(path)
+	if !bytes.HasPrefix(after, []byte{0x1f, 0x8b}) {
+		t.Fatal("expected gzip file after migration")
+	}
+	if len(after) >= len(before)/4 {
+		t.Fatalf("expected compressed file, %d -> %d bytes", len(before), len(after))
+	}
+
+	// 读取时无需配置压缩
+	store2, err := kv.NewKVStore[string](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store2.Close()
+	if v, _ := store2.Get("key-42"); v != strings.Repeat("value ", 20) {
+		t.Fatalf("unexpected value %q", v)
+	}
+}
+
+// prefixCompressor 测试用：只加魔数，不做真正压缩
+type prefixCompressor struct{}
+
+func (prefixCompressor) Name() string  { return "prefix" }
+func (prefixCompressor) Magic() []byte { return []byte("PFX!") }
+func (prefixCompressor) Compress(data []byte) ([]byte, error) {
+	return append([]byte("PFX!"), data...), nil
+}
+func (prefixCompressor) Decompress(data []byte) ([]byte, error) {
+	return bytes.TrimPrefix(data, []byte("PFX!")), nil
+}
+
+func TestKVStore_CustomCompressorWithEncryption(t *testing.T) {
+	kv.RegisterCompressor(prefixCompressor{})
+	path := filepath.Join(t.TempDir(), "custom.bin")
+	keys := kv.StaticKeys("k1", map[string][]byte{"k1": key1})
+	opts := []kv.Option{kv.WithCodec(kv.BinaryCodec), kv.WithCompression(prefixCompressor{}), kv.WithEncryption(keys)}
+
+	store, _ := kv.NewKVStore[int](path, opts...)
+	store.Set("n", 7)
+	store.Close()
+
+	store2, err := kv.NewKVStore[int](path, kv.WithEncryption(keys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store2.Close()
+	if v, _ := store2.Get("n"); v != 7 {
+		t.Fatalf("expected 7, got %d", v)
+	}
+}
@@ -0,0 +1,155 @@
+package kv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// ---------------------------
+// 静态加密（AES-GCM）
+// ---------------------------
+//
+// 加密后的内容布局：
+//   "GKE\x01" | 1 字节 key ID 长度 | key ID | 12 字节 nonce | 密文（含 GCM tag）
+// 魔数与 key ID 作为附加数据参与认证。快照、备份与每条 WAL 记录都按此格式封装，
+// 解密时按 key ID 向 KeyProvider 取密钥，因此轮换后旧文件仍可读取，并在下次保存时用新密钥重写。
+
+var sealMagic = []byte("GKE\x01")
+
+var (
+	// ErrWrongKey 密钥与 key ID 对应的密文不匹配（密钥错误或内容被篡改）
+	ErrWrongKey = errors.New("kv: wrong encryption key")
+	// ErrUnknownKey KeyProvider 中没有文件所用的 key ID（或未配置加密）
+	ErrUnknownKey = errors.New("kv: unknown encryption key")
+)
+
+// DecryptError 解密失败，Err 为 ErrWrongKey / ErrUnknownKey 或 KeyProvider 返回的错误
+type DecryptError struct {
+	KeyID string
+	Err   error
+}
+
+func (e *DecryptError) Error() string {
+	return fmt.Sprintf("kv: decrypt with key %q: %v", e.KeyID, e.Err)
+}
+
+func (e *DecryptError) Unwrap() error { return e.Err }
+
+// KeyProvider 提供加密密钥，密钥长度为 16 / 24 / 32 字节（AES-128 / 192 / 256）
+type KeyProvider interface {
+	// CurrentKey 返回用于加密的 key ID 与密钥，ID 长度不超过 255 字节
+	CurrentKey() (id string, key []byte, err error)
+	// Key 按 key ID 返回解密用的密钥，不存在时返回 ErrUnknownKey
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeys 返回固定密钥集合的 KeyProvider，current 为加密使用的 key ID。
+// 轮换时把新密钥加入 keys 并切换 current，旧密钥保留到所有文件都已重写。
+func StaticKeys(current string, keys map[string][]byte) KeyProvider {
+	return staticKeys{current: current, keys: keys}
+}
+
+type staticKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+func (k staticKeys) CurrentKey() (string, []byte, error) {
+	key, err := k.Key(k.current)
+	return k.current, key, err
+}
+
+func (k staticKeys) Key(id string) ([]byte, error) {
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// WithEncryption 使用 AES-GCM 加密持久化内容（快照、备份与 WAL）。
+// 加载未加密的旧文件时会在下次保存时加密重写。
+func WithEncryption(keys KeyProvider) Option {
+	return func(c *config) { c.keys = keys }
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal 用当前密钥加密 plain，返回密文与所用的 key ID
+func seal(keys KeyProvider, plain []byte) ([]byte, string, error) {
+	id, key, err := keys.CurrentKey()
+	if err != nil {
+		return nil, "", err
+	}
+	if len(id) > 255 {
+		return nil, "", fmt.Errorf("kv: key id too long: %d bytes", len(id))
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, "", err
+	}
+	head := make([]byte, 0, len(sealMagic)+1+len(id)+aead.NonceSize()+len(plain)+aead.Overhead())
+	head = append(head, sealMagic...)
+	head = append(head, byte(len(id)))
+	head = append(head, id...)
+	aad := head[:len(head):len(head)]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, "", err
+	}
+	out := append(head, nonce...)
+	return aead.Seal(out, nonce, plain, aad), id, nil
+}
+
+// unseal 解密 data；未加密的内容原样返回，keyID 为空
+func unseal(keys KeyProvider, data []byte) (plain []byte, keyID string, err error) {
+	if !bytes.HasPrefix(data, sealMagic) {
+		return data, "", nil
+	}
+	rest := data[len(sealMagic):]
+	if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+		return nil, "", &DecryptError{Err: ErrWrongKey}
+	}
+	n := int(rest[0])
+	keyID = string(rest[1 : 1+n])
+	aad := data[:len(sealMagic)+1+n]
+	rest = rest[1+n:]
+	if keys == nil {
+		return nil, keyID, &DecryptError{KeyID: keyID, Err: ErrUnknownKey}
+	}
+	key, err := keys.Key(keyID)
+	if err != nil {
+		return nil, keyID, &DecryptError{KeyID: keyID, Err: err}
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, keyID, &DecryptError{KeyID: keyID, Err: err}
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, keyID, &DecryptError{KeyID: keyID, Err: ErrWrongKey}
+	}
+	plain, err = aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], aad)
+	if err != nil {
+		return nil, keyID, &DecryptError{KeyID: keyID, Err: ErrWrongKey}
+	}
+	return plain, keyID, nil
+}
+
+// keyRotated 判断当前密钥是否已不同于上次写入快照所用的密钥（调用方需持有 saveMu）
+func (s *KVStore[V]) keyRotated() bool {
+	if s.keys == nil || s.keyID == "" {
+		return false
+	}
+	id, _, err := s.keys.CurrentKey()
+	return err == nil && id != s.keyID
+}
@@ -0,0 +1,121 @@
+package kv_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+var (
+	key1 = bytes.Repeat([]byte{1}, 32)
+	key2 = bytes.Repeat([]byte{2}, 32)
+)
+
+func TestKVStore_Encryption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.json")
+	keys := kv.StaticKeys("k1", map[string][]byte{"k1": key1})
+
+	store, err := kv.NewKVStore[string](path, kv.WithEncryption(keys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set("token", "super-secret-token")
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := os.ReadFile(path)
+	if bytes.Contains(raw, []byte("super-secret-token")) {
+		t.Fatal("expected file encrypted")
+	}
+
+	store2, err := kv.NewKVStore[string](path, kv.WithEncryption(keys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store2.Close()
+	if v, _ := store2.Get("token"); v != "super-secret-token" {
+		t.Fatalf("expected decrypted value, got %q", v)
+	}
+}
+
+func TestKVStore_EncryptionWrongKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.json")
+	store, _ := kv.NewKVStore[string](path, kv.WithEncryption(kv.StaticKeys("k1", map[string][]byte{"k1": key1})), kv.WithBackups(2))
+	store.Set("a", "1")
+	store.Close()
+
+	_, err := kv.NewKVStore[string](path, kv.WithEncryption(kv.StaticKeys("k1", map[string][]byte{"k1": key2})), kv.WithBackups(2))
+	var de *kv.DecryptError
+	if !errors.As(err, &de) || !errors.Is(err, kv.ErrWrongKey) || de.KeyID != "k1" {
+		t.Fatalf("expected DecryptError(ErrWrongKey), got %v", err)
+	}
+
+	_, err = kv.NewKVStore[string](path)
+	if !errors.Is(err, kv.ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey without provider, got %v", err)
+	}
+}
+
+func TestKVStore_EncryptionRotationAndMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.json")
+
+	// 明文旧文件在下次保存时加密
+	plain, _ := kv.NewKVStore[string](path)
+	plain.Set("a", "plain-value")
+	plain.Close()
+
+	store, err := kv.NewKVStore[string](path, kv.WithEncryption(kv.StaticKeys("k1", map[string][]byte{"k1": key1})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	raw, _ := os.ReadFile(path)
+	if bytes.Contains(raw, []byte("plain-value")) {
+		t.Fatal("expected plaintext file re-encrypted")
+	}
+
+	// 轮换到 k2：即使没有写入，下次保存也用新密钥重写
+	rotated := kv.StaticKeys("k2", map[string][]byte{"k1": key1, "k2": key2})
+	store, err = kv.NewKVStore[string](path, kv.WithEncryption(rotated))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store, err = kv.NewKVStore[string](path, kv.WithEncryption(kv.StaticKeys("k2", map[string][]byte{"k2": key2})))
+	if err != nil {
+		t.Fatalf("expected file re-encrypted with k2, got %v", err)
+	}
+	defer store.Close()
+	if v, _ := store.Get("a"); v != "plain-value" {
+		t.Fatalf("unexpected value %q", v)
+	}
+}
+
+func TestKVStore_EncryptionWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.json")
+	keys := kv.StaticKeys("k1", map[string][]byte{"k1": key1})
+	opts := []kv.Option{kv.WithWAL(true), kv.WithEncryption(keys), kv.WithSaveInterval(0)}
+
+	store, _ := kv.NewKVStore[string](path, opts...)
+	store.Set("token", "wal-secret")
+	raw, _ := os.ReadFile(path + ".wal")
+	if len(raw) == 0 || bytes.Contains(raw, []byte("wal-secret")) {
+		t.Fatal("expected encrypted wal records")
+	}
+
+	// 不 Close，模拟崩溃后从日志恢复
+	store2, err := kv.NewKVStore[string](path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store2.Close()
+	if v, _ := store2.Get("token"); v != "wal-secret" {
+		t.Fatalf("expected value replayed from encrypted wal, got %q", v)
+	}
+}
@@ -0,0 +1,379 @@
+package kv
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// ---------------------------
+// 容量限制与淘汰
+// ---------------------------
+
+// EvictionPolicy 达到容量上限时选择淘汰对象的策略
+type EvictionPolicy int
+
+const (
+	EvictLRU  EvictionPolicy = iota // 最久未访问（默认）
+	EvictLFU                        // 访问次数最少，次数相同时淘汰更早的
+	EvictFIFO                       // 最早写入
+)
+
+// WithMaxEntries 限制最大键数量（默认 0 不限制），写入新键超限时按策略淘汰
+func WithMaxEntries(n int) Option {
+	return func(c *config) { c.maxEntries = n }
+}
+
+// WithMaxBytes 限制估算的总字节数（默认 0 不限制）
+func WithMaxBytes(n int64) Option {
+	return func(c *config) { c.maxBytes = n }
+}
+
+// WithEvictionPolicy 设置淘汰策略（默认 EvictLRU）
+func WithEvictionPolicy(p EvictionPolicy) Option {
+	return func(c *config) { c.policy = p }
+}
+
+// WithSizer 自定义单个条目的字节估算（默认 len(key) + Codec 编码后的 value 长度）
+func WithSizer(fn func(key string, value any) int64) Option {
+	return func(c *config) { c.sizer = fn }
+}
+
+// Evictions 返回因容量限制被淘汰的条目数
+func (s *KVStore[V]) Evictions() uint64 {
+	if s.limit == nil {
+		return 0
+	}
+	return s.limit.evictions.Load()
+}
+
+// limiter 记录全局容量状态。条目数来自 KVStore.count，
+// 字节数由 mu 保护，policy 另有自己的锁，可在持有任意分片锁时调用。
+type limiter struct {
+	maxEntries int
+	maxBytes   int64
+	sizer      func(key string, value any) int64
+
+	policy evictPolicy
+
+	mu    sync.Mutex
+	sizes map[string]int64
+	bytes int64
+
+	evictions atomic.Uint64
+}
+
+func newLimiter(cfg config, codec Codec) *limiter {
+	if cfg.maxEntries <= 0 && cfg.maxBytes <= 0 {
+		return nil
+	}
+	l := &limiter{
+		maxEntries: cfg.maxEntries,
+		maxBytes:   cfg.maxBytes,
+		sizer:      cfg.sizer,
+		policy:     newPolicy(cfg.policy),
+		sizes:      make(map[string]int64),
+	}
+	if l.maxBytes > 0 && l.sizer == nil {
+		l.sizer = func(key string, value any) int64 {
+			b, err := codec.Marshal(value)
+			if err != nil {
+				return int64(len(key))
+			}
+			return int64(len(key) + len(b))
+		}
+	}
+	return l
+}
+
+// track 记录写入（新增或覆盖）
+func (l *limiter) track(key string, value any, existed bool) {
+	if existed {
+		l.policy.access(key)
+	} else {
+		l.policy.add(key)
+	}
+	if l.maxBytes > 0 {
+		size := l.sizer(key, value)
+		l.mu.Lock()
+		l.bytes += size - l.sizes[key]
+		l.sizes[key] = size
+		l.mu.Unlock()
+	}
+}
+
+func (l *limiter) untrack(key string) {
+	l.policy.remove(key)
+	if l.maxBytes > 0 {
+		l.mu.Lock()
+		l.bytes -= l.sizes[key]
+		delete(l.sizes, key)
+		l.mu.Unlock()
+	}
+}
+
+// reset 在各自的锁内清空策略与字节统计
+func (l *limiter) reset() {
+	l.policy.reset()
+	l.mu.Lock()
+	clear(l.sizes)
+	l.bytes = 0
+	l.mu.Unlock()
+}
+
+func (l *limiter) over(n int64) bool {
+	if l.maxEntries > 0 && n > int64(l.maxEntries) {
+		return true
+	}
+	if l.maxBytes > 0 {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.bytes > l.maxBytes
+	}
+	return false
+}
+
+// enforceLimit 超限时淘汰条目（在分片锁外调用，逐个锁住被淘汰键所在的分片），
+// keep 为刚写入的键，不会被淘汰
+func (s *KVStore[V]) enforceLimit(keep string) {
+	l := s.limit
+	if l == nil {
+		return
+	}
+	for l.over(s.count.Load()) {
+		victim, ok := l.policy.victim(keep)
+		if !ok {
+			return
+		}
+		sh := s.lock(victim)
+		if _, ok := sh.data[victim]; ok {
+			s.removeLocked(sh, victim, EventEvict)
+			l.evictions.Add(1)
+		} else {
+			// 并发删除中，策略尚未同步
+			l.policy.remove(victim)
+		}
+		s.release(sh)
+	}
+}
+
+// rebuildLimiter 在 load / 重放后重建容量状态并执行一次淘汰
+func (s *KVStore[V]) rebuildLimiter() {
+	if s.limit == nil {
+		return
+	}
+	s.lockAll()
+	s.resetLimiterLocked()
+	s.unlockAll("")
+}
+
+// resetLimiterLocked 按当前内容重建容量状态（调用方需持有全部分片的锁）。
+// enforceLimit 在分片锁外读取 limiter，因此只能原地清空而不能替换字段。
+func (s *KVStore[V]) resetLimiterLocked() {
+	s.limit.reset()
+	for _, sh := range s.shards {
+		for k, it := range sh.data {
+			s.limit.track(k, it.Value, false)
+		}
+	}
+}
+
+// ---------------------------
+// 淘汰策略实现
+// ---------------------------
+
+type evictPolicy interface {
+	add(key string)
+	access(key string)
+	remove(key string)
+	// reset 清空全部记录
+	reset()
+	// victim 返回下一个淘汰对象，跳过 keep
+	victim(keep string) (string, bool)
+}
+
+func newPolicy(p EvictionPolicy) evictPolicy {
+	switch p {
+	case EvictLFU:
+		return newLFU()
+	case EvictFIFO:
+		return newListPolicy(EvictFIFO)
+	default:
+		return newListPolicy(EvictLRU)
+	}
+}
+
+// listPolicy LRU 与 FIFO 共用的双向链表实现：front 最新，back 最旧
+type listPolicy struct {
+	mu    sync.Mutex
+	p     EvictionPolicy
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func newListPolicy(p EvictionPolicy) *listPolicy {
+	return &listPolicy{p: p, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (l *listPolicy) add(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.items[key]; ok {
+		l.ll.MoveToFront(e)
+		return
+	}
+	l.items[key] = l.ll.PushFront(key)
+}
+
+func (l *listPolicy) access(key string) {
+	if l.p == EvictFIFO {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.items[key]; ok {
+		l.ll.MoveToFront(e)
+	}
+}
+
+func (l *listPolicy) remove(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.items[key]; ok {
+		l.ll.Remove(e)
+		delete(l.items, key)
+	}
+}
+
+func (l *listPolicy) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ll.Init()
+	clear(l.items)
+}
+
+func (l *listPolicy) victim(keep string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for e := l.ll.Back(); e != nil; e = e.Prev() {
+		if k := e.Value.(string); k != keep {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// lfuPolicy 按访问频次分桶，每个桶内按时间排序
+type lfuPolicy struct {
+	mu    sync.Mutex
+	items map[string]*lfuEntry
+	freqs map[int]*list.List
+	min   int
+}
+
+type lfuEntry struct {
+	freq int
+	elem *list.Element
+}
+
+func newLFU() *lfuPolicy {
+	return &lfuPolicy{items: make(map[string]*lfuEntry), freqs: make(map[int]*list.List)}
+}
+
+func (l *lfuPolicy) push(key string, freq int) *list.Element {
+	ll, ok := l.freqs[freq]
+	if !ok {
+		ll = list.New()
+		l.freqs[freq] = ll
+	}
+	return ll.PushFront(key)
+}
+
+func (l *lfuPolicy) unlink(e *lfuEntry) {
+	ll := l.freqs[e.freq]
+	ll.Remove(e.elem)
+	if ll.Len() == 0 {
+		delete(l.freqs, e.freq)
+	}
+}
+
+func (l *lfuPolicy) add(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.items[key]; ok {
+		l.bump(key)
+		return
+	}
+	l.items[key] = &lfuEntry{freq: 1, elem: l.push(key, 1)}
+	l.min = 1
+}
+
+func (l *lfuPolicy) access(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bump(key)
+}
+
+func (l *lfuPolicy) bump(key string) {
+	e, ok := l.items[key]
+	if !ok {
+		return
+	}
+	l.unlink(e)
+	if e.freq == l.min && l.freqs[e.freq] == nil {
+		l.min++
+	}
+	e.freq++
+	e.elem = l.push(key, e.freq)
+}
+
+func (l *lfuPolicy) remove(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.items[key]; ok {
+		l.unlink(e)
+		delete(l.items, key)
+	}
+}
+
+func (l *lfuPolicy) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	clear(l.items)
+	clear(l.freqs)
+	l.min = 0
+}
+
+func (l *lfuPolicy) victim(keep string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// min 所在桶存在时必为最小频次；remove 可能清空该桶，此时退化为全量查找
+	if ll, ok := l.freqs[l.min]; ok {
+		if k, ok := oldestExcept(ll, keep); ok {
+			return k, true
+		}
+	}
+	best, bestFreq, minFreq := "", 0, 0
+	for f, ll := range l.freqs {
+		if minFreq == 0 || f < minFreq {
+			minFreq = f
+		}
+		if best != "" && f >= bestFreq {
+			continue
+		}
+		if k, ok := oldestExcept(ll, keep); ok {
+			best, bestFreq = k, f
+		}
+	}
+	l.min = minFreq
+	return best, best != ""
+}
+
+func oldestExcept(ll *list.List, keep string) (string, bool) {
+	for e := ll.Back(); e != nil; e = e.Prev() {
+		if k := e.Value.(string); k != keep {
+			return k, true
+		}
+	}
+	return "", false
+}
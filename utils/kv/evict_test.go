@@ -0,0 +1,138 @@
+package kv_test
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+func TestKVStore_EvictLRU(t *testing.T) {
+	store, err := kv.NewKVStore[int]("", kv.WithMaxEntries(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	store.Set("a", 1)
+	store.Set("b", 2)
+	store.Get("a") // a 变为最近访问
+	store.Set("c", 3)
+
+	if store.Exists("b") {
+		t.Fatal("expected b evicted as least recently used")
+	}
+	if !store.Exists("a") || !store.Exists("c") {
+		t.Fatalf("expected a and c kept, got %v", store.Keys())
+	}
+	if n := store.Evictions(); n != 1 {
+		t.Fatalf("expected 1 eviction, got %d", n)
+	}
+}
+
+func TestKVStore_EvictLFU(t *testing.T) {
+	store, err := kv.NewKVStore[int]("", kv.WithMaxEntries(2), kv.WithEvictionPolicy(kv.EvictLFU))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	store.Set("a", 1)
+	store.Set("b", 2)
+	store.Get("a")
+	store.Get("a")
+	store.Get("b")
+	store.Set("c", 3)
+
+	if store.Exists("b") {
+		t.Fatal("expected b evicted as least frequently used")
+	}
+	if !store.Exists("a") || !store.Exists("c") {
+		t.Fatalf("expected a and c kept, got %v", store.Keys())
+	}
+}
+
+func TestKVStore_EvictFIFO(t *testing.T) {
+	store, err := kv.NewKVStore[int]("", kv.WithMaxEntries(2), kv.WithEvictionPolicy(kv.EvictFIFO))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	store.Set("a", 1)
+	store.Set("b", 2)
+	store.Get("a") // FIFO 不受访问影响
+	store.Set("c", 3)
+
+	if store.Exists("a") {
+		t.Fatal("expected a evicted as first in")
+	}
+}
+
+func TestKVStore_EvictMaxBytes(t *testing.T) {
+	sizer := func(key string, value any) int64 { return 10 }
+	store, err := kv.NewKVStore[string]("", kv.WithMaxBytes(35), kv.WithSizer(sizer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	for i := 0; i < 10; i++ {
+		store.Set(fmt.Sprintf("k%d", i), "v")
+	}
+	if n := len(store.Keys()); n != 3 {
+		t.Fatalf("expected 3 keys within byte budget, got %d", n)
+	}
+	if n := store.Evictions(); n != 7 {
+		t.Fatalf("expected 7 evictions, got %d", n)
+	}
+}
+
+func TestKVStore_EvictDuringRestore(t *testing.T) {
+	src, _ := kv.NewKVStore[int]("")
+	defer src.Close()
+	for i := 0; i < 20; i++ {
+		src.Set(fmt.Sprintf("r%d", i), i)
+	}
+	var snap bytes.Buffer
+	if err := src.Snapshot(&snap); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := kv.NewKVStore[int]("", kv.WithMaxEntries(8), kv.WithMaxBytes(1<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	// Restore 重建容量状态时，写入方仍在分片锁外执行淘汰
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				store.Set(fmt.Sprintf("w%d-%d", g, i), i)
+			}
+		}()
+	}
+	for i := 0; i < 200; i++ {
+		if err := store.Restore(bytes.NewReader(snap.Bytes())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if n := len(store.Keys()); n > 8 {
+		t.Fatalf("expected at most 8 keys, got %d", n)
+	}
+}
@@ -0,0 +1,73 @@
+package kv
+
+import (
+	"container/heap"
+	"time"
+)
+
+// ---------------------------
+// 过期索引
+// ---------------------------
+//
+// 每个分片维护一个按过期时间排序的最小堆，清理时只弹出已到期的条目，不再扫描整个分片。
+// 覆盖或删除键时不从堆中移除旧条目（惰性失效）：弹出时与当前 item 的过期时间比对，
+// 不一致即丢弃；失效条目过多时按当前数据重建堆。
+
+// WithCleanupInterval 设置后台清理过期项的间隔（默认与 WithSaveInterval 相同）
+func WithCleanupInterval(d time.Duration) Option {
+	return func(c *config) { c.cleanupInterval = d }
+}
+
+type expiryEntry struct {
+	at  int64
+	key string
+}
+
+type expiryHeap []expiryEntry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].at < h[j].at }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x any)        { *h = append(*h, x.(expiryEntry)) }
+func (h *expiryHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// trackExpiry 记录带过期时间的键（调用方需持有 sh 的写锁）
+func (sh *shard[V]) trackExpiry(key string, at int64) {
+	if at <= 0 {
+		return
+	}
+	heap.Push(&sh.expiry, expiryEntry{at: at, key: key})
+	// 失效条目超过有效数据的两倍时重建
+	if len(sh.expiry) > 2*len(sh.data)+64 {
+		sh.rebuildExpiry()
+	}
+}
+
+// rebuildExpiry 按当前数据重建过期堆（调用方需持有 sh 的写锁）
+func (sh *shard[V]) rebuildExpiry() {
+	h := make(expiryHeap, 0, len(sh.expiry)/2)
+	for k, it := range sh.data {
+		if it.ExpireAt > 0 {
+			h = append(h, expiryEntry{at: it.ExpireAt, key: k})
+		}
+	}
+	heap.Init(&h)
+	sh.expiry = h
+}
+
+// dueLocked 弹出所有在 now 之前到期且仍然有效的键（调用方需持有 sh 的写锁）
+func (sh *shard[V]) dueLocked(now int64) []string {
+	var keys []string
+	for len(sh.expiry) > 0 && sh.expiry[0].at < now {
+		e := heap.Pop(&sh.expiry).(expiryEntry)
+		if it, ok := sh.data[e.key]; ok && it.ExpireAt == e.at {
+			keys = append(keys, e.key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,52 @@
+package kv_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+func TestKVStore_CleanupInterval(t *testing.T) {
+	// 保存间隔很长，清理间隔独立配置
+	store, err := kv.NewKVStore[int]("", kv.WithSaveInterval(time.Hour), kv.WithCleanupInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ch, cancel := store.Watch("")
+	defer cancel()
+	store.SetWithTTL("short", 1, 20*time.Millisecond)
+	store.Set("forever", 2)
+	recv(t, ch)
+	recv(t, ch)
+
+	if ev := recv(t, ch); ev.Type != kv.EventExpire || ev.Key != "short" {
+		t.Fatalf("expected short to expire by cleanup, got %+v", ev)
+	}
+}
+
+func TestKVStore_ExpiryRefreshedTTL(t *testing.T) {
+	store, _ := kv.NewKVStore[int]("", kv.WithSaveInterval(0), kv.WithCleanupInterval(5*time.Millisecond))
+	defer store.Close()
+
+	// 覆盖为更长的 ttl 后，旧的堆条目不应导致提前删除
+	store.SetWithTTL("k", 1, 10*time.Millisecond)
+	store.SetWithTTL("k", 2, time.Hour)
+	// 覆盖为不过期
+	store.SetWithTTL("p", 1, 10*time.Millisecond)
+	store.Set("p", 2)
+
+	// 反复刷新 ttl 触发堆重建
+	for i := 0; i < 500; i++ {
+		store.SetWithTTL("hot", i, time.Hour)
+	}
+	time.Sleep(40 * time.Millisecond)
+
+	for _, k := range []string{"k", "p", "hot"} {
+		if !store.Exists(k) {
+			t.Fatalf("expected %s kept after cleanup", k)
+		}
+	}
+}
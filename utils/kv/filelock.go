@@ -0,0 +1,87 @@
+package kv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ---------------------------
+// 跨进程文件锁
+// ---------------------------
+//
+// 锁文件为 <filePath>.lock（建议锁，Unix 下使用 flock；其它平台 New 返回 errors.ErrUnsupported）。
+//   LockExclusive   New 时以非阻塞方式独占，已被其它进程持有时立即返回 ErrLocked，持有到 Close。
+//   LockCooperative 多个进程共享同一文件：每次保存时短暂独占，先检查文件是否被外部修改，
+//                   有修改则按 ConflictPolicy 与内存合并后再写回（见 reload.go）。
+//                   没有本地修改时，后台循环仅在检测到外部修改时重新加载。
+//                   打开期间持有共享锁，与 LockExclusive 互斥：任一方已打开时另一方 New 返回 ErrLocked。
+
+// LockMode 跨进程锁模式
+type LockMode int
+
+const (
+	// LockNone 不加锁（默认）
+	LockNone LockMode = iota
+	// LockExclusive 独占：同一文件只能被一个 KVStore 打开
+	LockExclusive
+	// LockCooperative 协作：允许多个进程同时打开，保存时合并外部修改（不支持 WAL 模式）
+	LockCooperative
+)
+
+// ErrLocked 文件已被其它进程以独占模式打开
+var ErrLocked = errors.New("kv: file is locked by another process")
+
+// WithFileLock 设置跨进程锁模式（默认 LockNone）
+func WithFileLock(mode LockMode) Option {
+	return func(c *config) { c.lockMode = mode }
+}
+
+// fileLock 锁文件。f 在整个生命周期内持有：独占模式加排它锁，协作模式加共享锁，
+// 因此两种模式互斥，后打开的一方立即得到 ErrLocked。协作模式的保存另用 <filePath>.lock.save 串行化。
+type fileLock struct {
+	f    *os.File
+	save *os.File
+	mode LockMode
+}
+
+func openFileLock(filePath string, mode LockMode) (*fileLock, error) {
+	f, err := os.OpenFile(filePath+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l := &fileLock{f: f, mode: mode}
+	switch mode {
+	case LockExclusive:
+		err = flock(f, true, false)
+	case LockCooperative:
+		if err = flock(f, false, false); err == nil {
+			l.save, err = os.OpenFile(filePath+".lock.save", os.O_CREATE|os.O_RDWR, 0o644)
+		}
+	}
+	if err != nil {
+		l.close()
+		if errors.Is(err, errWouldBlock) {
+			return nil, fmt.Errorf("%w: %s", ErrLocked, filePath)
+		}
+		return nil, fmt.Errorf("kv: file lock %s: %w", filePath, err)
+	}
+	return l, nil
+}
+
+// lockSave 协作模式下独占保存锁，只与其它协作进程竞争，持有时间为一次读改写
+func (l *fileLock) lockSave() error {
+	return flock(l.save, true, true)
+}
+
+func (l *fileLock) unlockSave() {
+	_ = funlock(l.save)
+}
+
+func (l *fileLock) close() error {
+	if l.save != nil {
+		l.save.Close()
+	}
+	_ = funlock(l.f)
+	return l.f.Close()
+}
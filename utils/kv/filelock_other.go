@@ -0,0 +1,19 @@
+//go:build !unix
+
+package kv
+
+import (
+	"errors"
+	"os"
+)
+
+var errWouldBlock = errors.New("kv: lock would block")
+
+// flock 非 Unix 平台暂不支持文件锁
+func flock(f *os.File, exclusive, block bool) error {
+	return errors.ErrUnsupported
+}
+
+func funlock(f *os.File) error {
+	return nil
+}
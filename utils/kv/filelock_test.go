@@ -0,0 +1,132 @@
+package kv_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+func TestKVStore_ExclusiveLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "owned.json")
+	a, err := kv.NewKVStore[string](path, kv.WithFileLock(kv.LockExclusive))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := kv.NewKVStore[string](path, kv.WithFileLock(kv.LockExclusive)); !errors.Is(err, kv.ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+
+	// 独占方持有期间协作模式也不能打开
+	if _, err := kv.NewKVStore[string](path, kv.WithFileLock(kv.LockCooperative)); !errors.Is(err, kv.ErrLocked) {
+		t.Fatalf("expected ErrLocked for cooperative open, got %v", err)
+	}
+
+	a.Close()
+	b, err := kv.NewKVStore[string](path, kv.WithFileLock(kv.LockExclusive))
+	if err != nil {
+		t.Fatalf("expected lock released after Close, got %v", err)
+	}
+	b.Close()
+}
+
+func TestKVStore_CooperativeBlocksExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.json")
+	a, err := kv.NewKVStore[string](path, kv.WithFileLock(kv.LockCooperative), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 协作方打开期间独占打开立即失败，协作方的保存不受影响
+	if _, err := kv.NewKVStore[string](path, kv.WithFileLock(kv.LockExclusive)); !errors.Is(err, kv.ErrLocked) {
+		t.Fatalf("expected ErrLocked for exclusive open, got %v", err)
+	}
+	a.Set("k", "v")
+	done := make(chan error, 1)
+	go func() { done <- a.Save() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("cooperative save blocked")
+	}
+
+	a.Close()
+	b, err := kv.NewKVStore[string](path, kv.WithFileLock(kv.LockExclusive))
+	if err != nil {
+		t.Fatalf("expected exclusive open after cooperative Close, got %v", err)
+	}
+	b.Close()
+}
+
+func TestKVStore_CooperativeMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.json")
+	opts := []kv.Option{kv.WithFileLock(kv.LockCooperative), kv.WithSaveInterval(0)}
+
+	a, err := kv.NewKVStore[string](path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := kv.NewKVStore[string](path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	a.Set("shared", "a")
+	a.Set("only-a", "1")
+	if err := a.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// b 的本地修改优先，其余合并 a 写入的内容
+	b.Set("shared", "b")
+	b.Set("only-b", "2")
+	if err := b.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := b.Get("only-a"); v != "1" {
+		t.Fatalf("expected b to merge only-a, got %q", v)
+	}
+
+	// a 没有本地修改，保存时仅重新加载
+	ch, cancel := a.Watch("")
+	defer cancel()
+	a.Delete("only-a")
+	if err := a.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := a.Get("shared"); v != "b" {
+		t.Fatalf("expected a to reload shared=b, got %q", v)
+	}
+	if v, _ := a.Get("only-b"); v != "2" {
+		t.Fatalf("expected a to reload only-b, got %q", v)
+	}
+	if _, ok := a.Get("only-a"); ok {
+		t.Fatal("expected local delete to win")
+	}
+	recv(t, ch) // 本地删除
+	if ev := recv(t, ch); ev.Type != kv.EventSet {
+		t.Fatalf("expected set event for external change, got %+v", ev)
+	}
+
+	if err := b.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.Get("only-a"); ok {
+		t.Fatal("expected b to observe a's delete")
+	}
+}
+
+func TestKVStore_CooperativeRejectsWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shared.json")
+	if _, err := kv.NewKVStore[string](path, kv.WithFileLock(kv.LockCooperative), kv.WithWAL(true)); err == nil {
+		t.Fatal("expected error for cooperative lock with WAL")
+	}
+}
@@ -0,0 +1,204 @@
+// Package gormkv 提供把 kv.KVStore 持久化到数据库表的 kv.Backend 实现
+package gormkv
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 每行一个键，值用 Codec 编码后存入 value 列。
+// Bucket 内的键拆成 bucket 与 key 两列；key 按原始字节保存，不受数据库文本编码
+// （如 Postgres 不接受 NUL）与长度的限制，主键 id 是内部键的 SHA-256。
+type row struct {
+	ID       string `gorm:"column:id;primaryKey;size:64"`
+	Bucket   string `gorm:"column:bucket;index;size:255"` // 所属 Bucket，根命名空间为空
+	Key      []byte `gorm:"column:key"`
+	Value    []byte `gorm:"column:value"`
+	ExpireAt int64  `gorm:"column:expire_at;index"` // UnixNano，0 表示不过期
+	Sliding  int64  `gorm:"column:sliding"`         // 滑动过期窗口（纳秒）
+	Tags     string `gorm:"column:tags"`            // 标签（JSON 数组），无标签时为空
+}
+
+// Backend 基于 gorm.DB 的存储后端，实现 kv.DeltaBackend：
+// 定期保存只提交变化的键（upsert / delete），不会整表重写
+type Backend[V any] struct {
+	db        *gorm.DB
+	table     string
+	codec     kv.Codec
+	batchSize int
+}
+
+// Option 定义配置函数类型
+type Option func(*options)
+
+type options struct {
+	table     string
+	codec     kv.Codec
+	batchSize int
+}
+
+// WithTable 设置表名（默认 kv_entries）
+func WithTable(name string) Option {
+	return func(o *options) {
+		o.table = name
+	}
+}
+
+// WithCodec 设置值的编码方式（默认 kv.JSONCodec）
+func WithCodec(c kv.Codec) Option {
+	return func(o *options) {
+		o.codec = c
+	}
+}
+
+// WithBatchSize 设置批量写入的行数（默认 500）
+func WithBatchSize(n int) Option {
+	return func(o *options) {
+		o.batchSize = n
+	}
+}
+
+// 创建一个 gorm 存储后端，使用前需调用 Migrate 建表（或自行建表）
+func New[V any](db *gorm.DB, opts ...Option) *Backend[V] {
+	// 默认值
+	o := options{
+		table:     "kv_entries",
+		codec:     kv.JSONCodec,
+		batchSize: 500,
+	}
+
+	// 应用 Option
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return &Backend[V]{db: db, table: o.table, codec: o.codec, batchSize: o.batchSize}
+}
+
+// Migrate 创建或更新表结构
+func (b *Backend[V]) Migrate(ctx context.Context) error {
+	return b.db.WithContext(ctx).Table(b.table).AutoMigrate(&row{})
+}
+
+// Load 读取全部记录
+func (b *Backend[V]) Load(ctx context.Context) (map[string]kv.Entry[V], error) {
+	var rows []row
+	if err := b.db.WithContext(ctx).Table(b.table).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	entries := make(map[string]kv.Entry[V], len(rows))
+	for _, r := range rows {
+		var e kv.Entry[V]
+		if err := b.codec.Unmarshal(r.Value, &e.Value); err != nil {
+			return nil, err
+		}
+		if r.ExpireAt > 0 {
+			e.ExpireAt = time.Unix(0, r.ExpireAt)
+		}
+		e.Sliding = time.Duration(r.Sliding)
+		if r.Tags != "" {
+			if err := json.Unmarshal([]byte(r.Tags), &e.Tags); err != nil {
+				return nil, err
+			}
+		}
+		key := string(r.Key)
+		if r.Bucket != "" {
+			key = kv.BucketKey(r.Bucket, key)
+		}
+		entries[key] = e
+	}
+	return entries, nil
+}
+
+// Save 在一个事务内清空表并写入 snapshot
+func (b *Backend[V]) Save(ctx context.Context, snapshot map[string]kv.Entry[V]) error {
+	rows := make([]row, 0, len(snapshot))
+	for k, e := range snapshot {
+		r, err := b.encode(k, e)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, r)
+	}
+	return b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table(b.table).Where("1 = 1").Delete(&row{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.Table(b.table).CreateInBatches(rows, b.batchSize).Error
+	})
+}
+
+// Apply 在一个事务内提交增量变更
+func (b *Backend[V]) Apply(ctx context.Context, changes []kv.Change[V]) error {
+	var (
+		upserts []row
+		deletes []any
+	)
+	for _, c := range changes {
+		if c.Deleted {
+			deletes = append(deletes, rowID(c.Key))
+			continue
+		}
+		r, err := b.encode(c.Key, c.Entry)
+		if err != nil {
+			return err
+		}
+		upserts = append(upserts, r)
+	}
+	return b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 与写入一样按 batchSize 分批，避免超出数据库的参数个数上限
+		for chunk := range slices.Chunk(deletes, max(b.batchSize, 1)) {
+			in := clause.IN{Column: clause.Column{Name: "id"}, Values: chunk}
+			if err := tx.Table(b.table).Where(in).Delete(&row{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(upserts) == 0 {
+			return nil
+		}
+		return tx.Table(b.table).
+			Clauses(clause.OnConflict{UpdateAll: true}).
+			CreateInBatches(upserts, b.batchSize).Error
+	})
+}
+
+func (b *Backend[V]) encode(key string, e kv.Entry[V]) (row, error) {
+	value, err := b.codec.Marshal(e.Value)
+	if err != nil {
+		return row{}, err
+	}
+	r := row{ID: rowID(key), Value: value, Sliding: int64(e.Sliding)}
+	if bucket, k, ok := kv.ParseBucketKey(key); ok && bucket != "" {
+		r.Bucket, r.Key = bucket, []byte(k)
+	} else {
+		r.Key = []byte(key)
+	}
+	if !e.ExpireAt.IsZero() {
+		r.ExpireAt = e.ExpireAt.UnixNano()
+	}
+	if len(e.Tags) > 0 {
+		tags, err := json.Marshal(e.Tags)
+		if err != nil {
+			return row{}, err
+		}
+		r.Tags = string(tags)
+	}
+	return r, nil
+}
+
+// rowID 返回内部键对应的主键
+func rowID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
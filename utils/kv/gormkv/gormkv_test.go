@@ -0,0 +1,161 @@
+package gormkv_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+	"github.com/Yuelioi/gkit/utils/kv/gormkv"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "kv.db")
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func newBackend(t *testing.T, db *gorm.DB) *gormkv.Backend[string] {
+	t.Helper()
+	b := gormkv.New[string](db)
+	if err := b.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBackend_RoundTrip(t *testing.T) {
+	db := openDB(t)
+	store, err := kv.NewKVStoreWithBackend[string](newBackend(t, db), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	long := strings.Repeat("k", 1000)
+	store.Set("plain", "1")
+	store.SetWithTTL("ttl", "2", time.Hour)
+	store.SetWithTags("tagged", "3", 0, "red", "blue")
+	store.Set(long, "4")
+	store.Bucket("users").Set("alice", "5")
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := kv.NewKVStoreWithBackend[string](newBackend(t, db), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+
+	for key, want := range map[string]string{"plain": "1", "ttl": "2", "tagged": "3", long: "4"} {
+		if v, ok := reopened.Get(key); !ok || v != want {
+			t.Fatalf("expected %s=%s, got %q %v", key[:min(len(key), 16)], want, v, ok)
+		}
+	}
+	if v, ok := reopened.Bucket("users").Get("alice"); !ok || v != "5" {
+		t.Fatalf("expected bucket key restored, got %q %v", v, ok)
+	}
+	if ttl := reopened.TTL("ttl"); ttl.State != kv.TTLExpiring {
+		t.Fatalf("expected ttl kept, got %+v", ttl)
+	}
+	if tags := reopened.Tags("tagged"); !slices.Equal(tags, []string{"blue", "red"}) {
+		t.Fatalf("expected tags kept, got %v", tags)
+	}
+
+	// Bucket 名与键分列保存
+	var bucket string
+	if err := db.Table("kv_entries").Select("bucket").Where("key = ?", []byte("alice")).Scan(&bucket).Error; err != nil {
+		t.Fatal(err)
+	}
+	if bucket != "users" {
+		t.Fatalf("expected bucket column, got %q", bucket)
+	}
+}
+
+func TestBackend_Apply(t *testing.T) {
+	db := openDB(t)
+	store, err := kv.NewKVStoreWithBackend[string](newBackend(t, db), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	users := store.Bucket("users")
+	users.Set("alice", "1")
+	users.Set("bob", "2")
+	store.Set("root", "3")
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	users.Set("alice", "10")
+	users.Delete("bob")
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := newBackend(t, db).Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(entries))
+	}
+	if e := entries[kv.BucketKey("users", "alice")]; e.Value != "10" {
+		t.Fatalf("expected alice updated, got %q", e.Value)
+	}
+	if _, ok := entries[kv.BucketKey("users", "bob")]; ok {
+		t.Fatal("expected bob deleted")
+	}
+}
+
+func TestBackend_ApplyManyDeletes(t *testing.T) {
+	db := openDB(t)
+	b := gormkv.New[string](db, gormkv.WithBatchSize(100))
+	if err := b.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	store, err := kv.NewKVStoreWithBackend[string](b, kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	// 删除数超过一批（以及 SQLite 的参数上限）时仍能提交
+	const n = 40000
+	for i := 0; i < n; i++ {
+		store.SetWithTags(fmt.Sprintf("k%d", i), "v", 0, "t")
+	}
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.InvalidateTag("t"); got != n {
+		t.Fatalf("expected %d invalidated, got %d", n, got)
+	}
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := b.Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected all rows deleted, got %d", len(entries))
+	}
+}
@@ -0,0 +1,230 @@
+package kv
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ---------------------------
+// 二级索引
+// ---------------------------
+//
+// 索引把值映射为若干索引值（如 Session → UserID），LookupIndex 按索引值反查键，
+// 无需遍历全部数据。索引在每次写入、删除、过期与重新加载时同步维护，只存在于内存中，
+// 不持久化；Bucket 内的键不参与根存储的索引。
+
+var (
+	// ErrIndexExists AddIndex 时同名索引已存在
+	ErrIndexExists = errors.New("kv: index already exists")
+	// ErrNoIndex 索引不存在
+	ErrNoIndex = errors.New("kv: index not found")
+)
+
+type index[V any] struct {
+	fn func(V) []string
+
+	mu    sync.RWMutex
+	byVal map[string]map[string]struct{} // 索引值 → 键
+	byKey map[string][]string            // 键 → 索引值
+}
+
+func newIndex[V any](fn func(V) []string) *index[V] {
+	return &index[V]{
+		fn:    fn,
+		byVal: make(map[string]map[string]struct{}),
+		byKey: make(map[string][]string),
+	}
+}
+
+func (ix *index[V]) put(key string, value V) {
+	ix.set(key, ix.fn(value))
+}
+
+// set 把 key 的索引值替换为 vals
+func (ix *index[V]) set(key string, vals []string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.removeLocked(key)
+	if len(vals) == 0 {
+		return
+	}
+	for _, v := range vals {
+		keys := ix.byVal[v]
+		if keys == nil {
+			keys = make(map[string]struct{})
+			ix.byVal[v] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	ix.byKey[key] = vals
+}
+
+func (ix *index[V]) remove(key string) {
+	ix.mu.Lock()
+	ix.removeLocked(key)
+	ix.mu.Unlock()
+}
+
+func (ix *index[V]) removeLocked(key string) {
+	for _, v := range ix.byKey[key] {
+		keys := ix.byVal[v]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(ix.byVal, v)
+		}
+	}
+	delete(ix.byKey, key)
+}
+
+func (ix *index[V]) lookup(value string) []string {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	keys := make([]string, 0, len(ix.byVal[value]))
+	for k := range ix.byVal[value] {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// AddIndex 注册名为 name 的索引，fn 返回值对应的索引值（可以为多个或为空），并立即为现有数据建立索引。
+// fn 在持有存储锁时调用，不能调用 KVStore 的方法。
+func (s *KVStore[V]) AddIndex(name string, fn func(V) []string) error {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	cur := s.indexes.Load()
+	if cur != nil {
+		if _, ok := (*cur)[name]; ok {
+			return ErrIndexExists
+		}
+	}
+
+	// 持有全部分片读锁建立索引并发布，期间的写入会等到发布之后再维护索引
+	ix := newIndex(fn)
+	s.rlockAll()
+	defer s.runlockAll()
+	for _, sh := range s.shards {
+		for k, it := range sh.data {
+			if !isBucketKey(k) {
+				ix.put(k, it.Value)
+			}
+		}
+	}
+	next := make(map[string]*index[V], 1)
+	if cur != nil {
+		for n, other := range *cur {
+			next[n] = other
+		}
+	}
+	next[name] = ix
+	s.indexes.Store(&next)
+	return nil
+}
+
+// DropIndex 移除索引，不存在时返回 ErrNoIndex
+func (s *KVStore[V]) DropIndex(name string) error {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	cur := s.indexes.Load()
+	if cur == nil {
+		return ErrNoIndex
+	}
+	if _, ok := (*cur)[name]; !ok {
+		return ErrNoIndex
+	}
+	next := make(map[string]*index[V], len(*cur))
+	for n, ix := range *cur {
+		if n != name {
+			next[n] = ix
+		}
+	}
+	s.indexes.Store(&next)
+	return nil
+}
+
+// LookupIndex 返回索引 name 中索引值为 value 的所有未过期键（按字典序）
+func (s *KVStore[V]) LookupIndex(name, value string) ([]string, error) {
+	ix := s.index(name)
+	if ix == nil {
+		return nil, ErrNoIndex
+	}
+	keys := ix.lookup(value)
+	now := time.Now().UnixNano()
+	live := keys[:0]
+	for _, k := range keys {
+		sh := s.shardFor(k)
+		sh.mu.RLock()
+		_, ok := liveLocked(sh, k, now)
+		sh.mu.RUnlock()
+		if ok {
+			live = append(live, k)
+		}
+	}
+	slices.Sort(live)
+	return live, nil
+}
+
+func (s *KVStore[V]) index(name string) *index[V] {
+	cur := s.indexes.Load()
+	if cur == nil {
+		return nil
+	}
+	return (*cur)[name]
+}
+
+// indexPut 在写入后维护索引（调用方持有 key 所在分片的写锁）
+func (s *KVStore[V]) indexPut(key string, value V) {
+	cur := s.indexes.Load()
+	if cur == nil || isBucketKey(key) {
+		return
+	}
+	for _, ix := range *cur {
+		ix.put(key, value)
+	}
+}
+
+// indexRemove 在删除后维护索引（调用方持有 key 所在分片的写锁）
+func (s *KVStore[V]) indexRemove(key string) {
+	cur := s.indexes.Load()
+	if cur == nil {
+		return
+	}
+	for _, ix := range *cur {
+		ix.remove(key)
+	}
+}
+
+func (ix *index[V]) reset() {
+	ix.mu.Lock()
+	ix.byVal = make(map[string]map[string]struct{})
+	ix.byKey = make(map[string][]string)
+	ix.mu.Unlock()
+}
+
+// reindexLocked 按当前内容重建全部索引与标签（调用方需持有全部分片的锁）
+func (s *KVStore[V]) reindexLocked() {
+	s.tags.reset()
+	for _, sh := range s.shards {
+		for k, it := range sh.data {
+			if len(it.Tags) > 0 {
+				s.tags.set(k, it.Tags)
+			}
+		}
+	}
+
+	cur := s.indexes.Load()
+	if cur == nil {
+		return
+	}
+	for _, ix := range *cur {
+		ix.reset()
+		for _, sh := range s.shards {
+			for k, it := range sh.data {
+				if !isBucketKey(k) {
+					ix.put(k, it.Value)
+				}
+			}
+		}
+	}
+}
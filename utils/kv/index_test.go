@@ -0,0 +1,109 @@
+package kv_test
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+type session struct {
+	User  string
+	Roles []string
+}
+
+func TestKVStore_Index(t *testing.T) {
+	store, err := kv.NewKVStore[session]("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	store.Set("s1", session{User: "alice", Roles: []string{"admin"}})
+	// 为已有数据建立索引
+	if err := store.AddIndex("user", func(s session) []string { return []string{s.User} }); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddIndex("user", func(s session) []string { return nil }); !errors.Is(err, kv.ErrIndexExists) {
+		t.Fatalf("expected ErrIndexExists, got %v", err)
+	}
+	if err := store.AddIndex("role", func(s session) []string { return s.Roles }); err != nil {
+		t.Fatal(err)
+	}
+
+	store.Set("s2", session{User: "alice", Roles: []string{"admin", "dev"}})
+	store.Set("s3", session{User: "bob"})
+	store.SetWithTTL("s4", session{User: "alice"}, time.Millisecond)
+
+	assertLookup := func(name, value string, want ...string) {
+		t.Helper()
+		got, err := store.LookupIndex(name, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("lookup %s=%s: expected %v, got %v", name, value, want, got)
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	assertLookup("user", "alice", "s1", "s2")
+	assertLookup("role", "admin", "s1", "s2")
+	assertLookup("role", "dev", "s2")
+
+	// 覆盖时移除旧索引值
+	store.Set("s2", session{User: "bob"})
+	assertLookup("user", "alice", "s1")
+	assertLookup("role", "dev")
+	assertLookup("user", "bob", "s2", "s3")
+
+	store.Delete("s3")
+	assertLookup("user", "bob", "s2")
+
+	if _, err := store.LookupIndex("missing", "x"); !errors.Is(err, kv.ErrNoIndex) {
+		t.Fatalf("expected ErrNoIndex, got %v", err)
+	}
+	if err := store.DropIndex("role"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.LookupIndex("role", "admin"); !errors.Is(err, kv.ErrNoIndex) {
+		t.Fatalf("expected ErrNoIndex after drop, got %v", err)
+	}
+}
+
+func TestKVStore_IndexRebuiltOnRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	store, err := kv.NewKVStore[session](path, kv.WithSaveInterval(0), kv.WithBackups(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := store.AddIndex("user", func(s session) []string { return []string{s.User} }); err != nil {
+		t.Fatal(err)
+	}
+
+	store.Set("s1", session{User: "alice"})
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Now()
+	time.Sleep(2 * time.Millisecond)
+	store.Set("s2", session{User: "alice"})
+	store.Set("s1", session{User: "bob"})
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.RestoreAt(at); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.LookupIndex("user", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []string{"s1"}) {
+		t.Fatalf("expected index rebuilt from restored data, got %v", got)
+	}
+}
@@ -0,0 +1,158 @@
+package kv
+
+import (
+	"bufio"
+	"maps"
+	"os"
+)
+
+// ---------------------------
+// 只读检查
+// ---------------------------
+
+// FileInfo 持久化文件的格式与内容概要，见 ReadFile
+type FileInfo struct {
+	Codec      Codec      // 快照的 Codec（快照不存在时为 nil）
+	Compressor Compressor // nil 表示未压缩
+	KeyID      string     // 加密使用的 key ID，空表示未加密
+	Entries    int        // 快照中的条目数（含已过期）
+	WAL        bool       // 是否存在日志文件
+	WALRecords int        // 日志中尚未合并进快照的记录数
+	WALCorrupt int64      // 日志末尾残缺或损坏的字节数
+}
+
+// ReadFile 只读地解码 filePath 的快照与日志，返回合并后的全部条目（含已过期的）。
+// 不创建、修改或锁定任何文件；主文件损坏时直接返回错误，不回退到备份。
+// opts 中仅 WithCodec / WithStrictCodec / WithEncryption 生效。
+func ReadFile[V any](filePath string, opts ...Option) (map[string]Entry[V], FileInfo, error) {
+	data := make(map[string]item[V])
+	var apply func(rec walRecord[V])
+	apply = func(rec walRecord[V]) {
+		switch rec.Op {
+		case walOpSet:
+			if rec.Item != nil {
+				data[rec.Key] = *rec.Item
+			}
+		case walOpDelete:
+			delete(data, rec.Key)
+		case walOpBatch:
+			for _, op := range rec.Ops {
+				apply(op)
+			}
+		}
+	}
+	info, err := readFile(filePath, opts, data, apply)
+	if err != nil {
+		return nil, info, err
+	}
+	entries := make(map[string]Entry[V], len(data))
+	for k, it := range data {
+		entries[k] = toEntry(it)
+	}
+	return entries, info, nil
+}
+
+// itemMeta 是不含值的 item：解码时跳过 value 字段（JSON、gob 与 Binary 都忽略未知字段）
+type itemMeta struct {
+	ExpireAt int64    `json:"expire_at,omitempty"`
+	Slide    int64    `json:"slide,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// walMetaRecord 是不含值的 walRecord
+type walMetaRecord struct {
+	Op   walOp           `json:"op"`
+	Key  string          `json:"key,omitempty"`
+	Item *itemMeta       `json:"item,omitempty"`
+	Ops  []walMetaRecord `json:"ops,omitempty"`
+}
+
+// ReadKeys 与 ReadFile 相同，但只读取键、过期时间与标签，不解码值（Entry.Value 为空）。
+// 适用于不知道值类型的工具，例如查看 gob 文件的键与概要。
+func ReadKeys(filePath string, opts ...Option) (map[string]Entry[struct{}], FileInfo, error) {
+	data := make(map[string]itemMeta)
+	var apply func(rec walMetaRecord)
+	apply = func(rec walMetaRecord) {
+		switch rec.Op {
+		case walOpSet:
+			if rec.Item != nil {
+				data[rec.Key] = *rec.Item
+			}
+		case walOpDelete:
+			delete(data, rec.Key)
+		case walOpBatch:
+			for _, op := range rec.Ops {
+				apply(op)
+			}
+		}
+	}
+	info, err := readFile(filePath, opts, data, apply)
+	if err != nil {
+		return nil, info, err
+	}
+	entries := make(map[string]Entry[struct{}], len(data))
+	for k, m := range data {
+		entries[k] = toEntry(item[struct{}]{ExpireAt: m.ExpireAt, Slide: m.Slide, Tags: m.Tags})
+	}
+	return entries, info, nil
+}
+
+// readFile 把快照解码进 data，再依次应用轮转前后的日志
+func readFile[E, R any](filePath string, opts []Option, data map[string]E, apply func(R)) (FileInfo, error) {
+	var cfg config
+	for _, o := range opts {
+		o(&cfg)
+	}
+	f := fileFormat{codec: cfg.codec, keys: cfg.keys}
+	if f.codec == nil {
+		f.codec = JSONCodec
+	}
+
+	var info FileInfo
+	raw, err := os.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return info, err
+	}
+	if len(raw) > 0 {
+		snap, meta, err := decodeFileAs[E](f, raw, cfg.strictCodec)
+		if err != nil {
+			return info, err
+		}
+		maps.Copy(data, snap)
+		info.Codec, info.Compressor, info.KeyID = meta.codec, meta.compressor, meta.keyID
+		info.Entries = len(snap)
+	}
+
+	w := walLog{path: filePath + ".wal"}
+	for _, p := range []string{w.rotatedPath(), w.path} {
+		n, corrupt, err := readWALFile(p, f.keys, apply)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return info, err
+		}
+		info.WAL = true
+		info.WALRecords += n
+		info.WALCorrupt += corrupt
+	}
+	return info, nil
+}
+
+// readWALFile 把日志文件中的记录交给 apply，返回记录数与末尾无效的字节数
+func readWALFile[R any](path string, keys KeyProvider, apply func(R)) (int, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+	st, err := file.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	valid, n, _, _, err := readFrames(bufio.NewReader(file), st.Size(), keys, apply)
+	if err != nil {
+		return n, 0, err
+	}
+	return n, st.Size() - valid, nil
+}
@@ -0,0 +1,117 @@
+package kv_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inspect.db")
+	keys := kv.StaticKeys("k1", map[string][]byte{"k1": make([]byte, 32)})
+	opts := []kv.Option{kv.WithCodec(kv.BinaryCodec), kv.WithCompression(kv.GzipCompressor), kv.WithEncryption(keys), kv.WithSaveInterval(0)}
+
+	store, err := kv.NewKVStore[string](path, append(opts, kv.WithWAL(true))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetWithTags("a", "1", time.Hour, "t")
+	store.Set("b", "2")
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	store.Set("c", "3")
+	store.Delete("b")
+
+	entries, info, err := kv.ReadFile[string](path, kv.WithEncryption(keys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Codec.Name() != "binary" || info.Compressor.Name() != "gzip" || info.KeyID != "k1" {
+		t.Fatalf("unexpected format %+v", info)
+	}
+	if info.Entries != 2 || !info.WAL || info.WALRecords != 2 || info.WALCorrupt != 0 {
+		t.Fatalf("unexpected counts %+v", info)
+	}
+	if len(entries) != 2 || entries["a"].Value != "1" || entries["a"].ExpireAt.IsZero() || entries["c"].Value != "3" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+	if len(entries["a"].Tags) != 1 {
+		t.Fatalf("expected tags, got %+v", entries["a"])
+	}
+
+	// 残缺的日志尾部只统计，不截断
+	f, err := os.OpenFile(path+".wal", os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 0, 9, 1})
+	f.Close()
+	before, _ := os.Stat(path + ".wal")
+	if _, info, err = kv.ReadFile[string](path, kv.WithEncryption(keys)); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.Stat(path + ".wal")
+	if info.WALCorrupt != 5 || before.Size() != after.Size() {
+		t.Fatalf("expected 5 corrupt bytes left in place, got %d (%d -> %d)", info.WALCorrupt, before.Size(), after.Size())
+	}
+	store.Close()
+}
+
+func TestReadKeys(t *testing.T) {
+	type session struct{ User string }
+	path := filepath.Join(t.TempDir(), "keys.gob")
+	store, err := kv.NewKVStore[session](path, kv.WithCodec(kv.GobCodec), kv.WithWAL(true), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.SetWithTags("a", session{"alice"}, time.Hour, "t")
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	store.Bucket("b").Set("x", session{"bob"})
+
+	// 不知道值类型也能读出键与元数据
+	entries, info, err := kv.ReadKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Codec.Name() != "gob" || info.Entries != 1 || info.WALRecords != 1 {
+		t.Fatalf("unexpected info %+v", info)
+	}
+	if len(entries) != 2 || entries["a"].ExpireAt.IsZero() || len(entries["a"].Tags) != 1 {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+	if _, ok := entries[kv.BucketKey("b", "x")]; !ok {
+		t.Fatalf("expected bucket key from wal, got %+v", entries)
+	}
+}
+
+func TestKVStore_Compact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "compact.json")
+	store, err := kv.NewKVStore[int](path, kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.SetWithTTL("a", 1, time.Millisecond)
+	store.Set("b", 2)
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := store.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	entries, _, err := kv.ReadFile[int](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := entries["a"]; ok || len(entries) != 1 {
+		t.Fatalf("expected expired entry dropped, got %+v", entries)
+	}
+}
@@ -1,10 +1,13 @@
 package kv
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/maphash"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,12 +18,48 @@ import (
 type Option func(*config)
 
 type config struct {
-	interval   time.Duration // 背景保存/清理间隔
-	pretty     bool          // 是否使用 MarshalIndent
-	loadOnInit bool          // 是否在 New 时加载文件 (默认 true)
+	interval        time.Duration // 背景保存间隔
+	cleanupInterval time.Duration // 背景清理间隔（默认同 interval）
+	pretty          bool          // 是否使用 MarshalIndent
+	loadOnInit      bool          // 是否在 New 时加载文件 (默认 true)
+
+	wal          bool  // 是否启用追加写日志 (WAL) 模式
+	walSync      bool  // 每次追加后是否 fsync
+	compactBytes int64 // WAL 超过该大小时在后台压缩为快照
+
+	codec       Codec // 持久化编码（默认 JSON）
+	strictCodec bool  // 文件 Codec 不一致时是否报错
+
+	maxEntries int                               // 最大键数量
+	maxBytes   int64                             // 最大估算字节数
+	policy     EvictionPolicy                    // 淘汰策略
+	sizer      func(key string, value any) int64 // 条目大小估算
+
+	watchBuffer int         // 订阅 channel 缓冲
+	watchPolicy WatchPolicy // 缓冲满时的策略
+
+	shards int // 分片数量
+
+	backups        int           // 保留的备份份数
+	backupInterval time.Duration // 两次备份的最小间隔
+	backupDir      string        // 备份目录
+
+	keys KeyProvider // 静态加密密钥
+
+	compressor Compressor // 快照压缩
+
+	lockMode LockMode // 跨进程锁模式
+
+	reloadInterval time.Duration  // 热加载轮询间隔
+	conflict       ConflictPolicy // 外部修改的冲突策略
+
+	sliding bool // 是否滑动过期
+
+	metricsInterval time.Duration // 统计导出间隔
+	metrics         func(Stats)   // 统计导出
 }
 
-// WithSaveInterval 设置后台保存与清理的间隔 (默认 1m)
+// WithSaveInterval 设置后台保存的间隔 (默认 1m)，未设置 WithCleanupInterval 时同时作为清理间隔
 func WithSaveInterval(d time.Duration) Option {
 	return func(c *config) { c.interval = d }
 }
@@ -35,42 +74,126 @@ func WithLoadOnInit(b bool) Option {
 	return func(c *config) { c.loadOnInit = b }
 }
 
+// WithWAL 启用追加写日志模式（默认 false）。
+// 开启后每次 Set/SetWithTTL/Delete 都会立即追加到 <filePath>.wal，
+// 后台仅在日志超过阈值时才把全量数据压缩为快照，load 时按 快照 + 日志 重放。
+func WithWAL(enabled bool) Option {
+	return func(c *config) { c.wal = enabled }
+}
+
+// WithWALSync 设置每次追加日志后是否 fsync（默认 false，仅保证进程崩溃不丢数据）
+func WithWALSync(sync bool) Option {
+	return func(c *config) { c.walSync = sync }
+}
+
+// WithCompactThreshold 设置 WAL 触发压缩的大小阈值（默认 4MB）
+func WithCompactThreshold(bytes int64) Option {
+	return func(c *config) { c.compactBytes = bytes }
+}
+
 // ---------------------------
 // Core types
 // ---------------------------
 
 // item 内部存储结构，使用 UnixNano 表示时间（0 表示不过期）
 type item[V any] struct {
-	Value    V     `json:"value"`
-	ExpireAt int64 `json:"expire_at,omitempty"`
+	Value    V        `json:"value"`
+	ExpireAt int64    `json:"expire_at,omitempty"`
+	Slide    int64    `json:"slide,omitempty"` // 滑动过期窗口（纳秒），0 表示不滑动
+	Tags     []string `json:"tags,omitempty"`  // 标签，见 SetWithTags
+}
+
+func (it item[V]) expired(now int64) bool {
+	return it.ExpireAt > 0 && now > it.ExpireAt
 }
 
 // KVStore 泛型持久化 KV
 type KVStore[V any] struct {
-	mu       sync.RWMutex
-	data     map[string]item[V]
+	shards   []*shard[V]
+	seed     maphash.Seed
+	count    atomic.Int64 // 条目总数（含尚未清理的过期项）
 	filePath string
 
 	// 状态
-	dirty bool
+	dirty  atomic.Bool
+	saveMu sync.Mutex // 串行化 save，快照复制在分片锁内、编码与写盘在锁外
 
 	// 背景任务
-	saveInterval time.Duration
-	stopCh       chan struct{}
-	wg           sync.WaitGroup
+	saveInterval    time.Duration
+	cleanupInterval time.Duration
+	stopCh          chan struct{}
+	wg              sync.WaitGroup
 
 	// 配置
-	pretty bool
+	codec       Codec
+	strictCodec bool
+	sliding     bool
+
+	// 静态加密（nil 表示不加密）；keyID 为最近一次读写快照所用的 key ID（受 saveMu 保护）
+	keys  KeyProvider
+	keyID string
+
+	// 快照压缩（nil 表示不压缩）
+	compressor Compressor
+
+	// WAL 模式（nil 表示未启用）；walBatch 非空时表示 Batch 正在收集记录（Batch 持有全部分片锁）
+	wal      *walLog
+	walBatch *[]walRecord[V]
+
+	// 容量限制（nil 表示不限制）
+	limit *limiter
+
+	// 变更订阅；batchEvents 非空时表示 Batch 正在按顺序收集事件
+	watch       *hub[V]
+	batchEvents *[]Event[V]
+
+	// 滚动备份（memory-only 模式下为 nil）
+	backup *backupSet
+
+	// 跨进程文件锁（nil 表示不加锁）
+	flock *fileLock
+
+	// 外部修改检测（仅热加载 / 协作锁模式，否则为 nil）
+	fsync    *fileSync
+	conflict ConflictPolicy
+	// 自上次保存以来写过的键（热加载 / 协作锁 / 增量后端使用，否则为 nil）
+	changes *changeSet
+
+	// 存储后端（nil 表示使用 filePath 文件）
+	backend Backend[V]
+
+	// 统计与后台错误回调
+	stats       counters
+	onSaveError atomic.Pointer[func(error)]
+	metrics     func(Stats)
+
+	// 命名空间
+	bucketsMu sync.Mutex
+	buckets   map[string]*Bucket[V]
+
+	// 二级索引（写时复制，indexMu 串行化增删索引）
+	indexMu sync.Mutex
+	indexes atomic.Pointer[map[string]*index[V]]
+	// 标签 → 键
+	tags *index[V]
 }
 
 // NewKVStore 创建 KVStore。
 // filePath 为空表示 memory-only 模式（不做磁盘 IO）。
 // 默认 saveInterval = 1 minute, pretty = false, loadOnInit = true
 func NewKVStore[V any](filePath string, opts ...Option) (*KVStore[V], error) {
+	return newStore[V](filePath, nil, opts)
+}
+
+func newStore[V any](filePath string, backend Backend[V], opts []Option) (*KVStore[V], error) {
 	cfg := config{
 		interval:   time.Minute,
 		pretty:     false,
 		loadOnInit: true,
+
+		compactBytes: 4 << 20,
+		watchBuffer:  64,
+		shards:       16,
 	}
 	for _, o := range opts {
 		o(&cfg)
@@ -85,25 +208,111 @@ func NewKVStore[V any](filePath string, opts ...Option) (*KVStore[V], error) {
 	}
 
 	s := &KVStore[V]{
-		data:         make(map[string]item[V]),
-		filePath:     filePath,
-		saveInterval: cfg.interval,
-		stopCh:       make(chan struct{}),
-		pretty:       cfg.pretty,
+		shards:          newShards[V](cfg.shards),
+		seed:            maphash.MakeSeed(),
+		filePath:        filePath,
+		saveInterval:    cfg.interval,
+		cleanupInterval: cfg.cleanupInterval,
+		stopCh:          make(chan struct{}),
+		codec:           cfg.codec,
+		strictCodec:     cfg.strictCodec,
+		sliding:         cfg.sliding,
+		keys:            cfg.keys,
+		compressor:      cfg.compressor,
+		conflict:        cfg.conflict,
+		metrics:         cfg.metrics,
+		watch:           newHub[V](cfg.watchBuffer, cfg.watchPolicy),
+		tags:            newIndex[V](nil),
+		backend:         backend,
+	}
+	if s.codec == nil {
+		s.codec = jsonCodec{indent: cfg.pretty}
+	}
+	if s.cleanupInterval == 0 {
+		s.cleanupInterval = s.saveInterval
+	}
+	s.limit = newLimiter(cfg, s.codec)
+	if filePath != "" {
+		s.backup = newBackupSet(filePath, cfg)
 	}
 
-	if cfg.loadOnInit && filePath != "" {
+	started := false
+	if filePath != "" && (cfg.lockMode == LockCooperative || cfg.reloadInterval > 0) {
+		if cfg.wal {
+			return nil, errors.New("kv: LockCooperative and WithHotReload do not support WAL mode")
+		}
+		s.fsync = &fileSync{}
+		s.changes = newChangeSet()
+	}
+	if cfg.lockMode != LockNone && filePath != "" {
+		l, err := openFileLock(filePath, cfg.lockMode)
+		if err != nil {
+			return nil, err
+		}
+		s.flock = l
+		// 初始化失败时释放锁
+		defer func() {
+			if !started {
+				l.close()
+			}
+		}()
+	}
+
+	if backend != nil {
+		if _, ok := backend.(DeltaBackend[V]); ok {
+			s.changes = newChangeSet()
+		}
+	}
+
+	if cfg.loadOnInit && (filePath != "" || backend != nil) {
 		if err := s.load(); err != nil {
 			return nil, err
 		}
 	}
 
+	if cfg.wal && filePath != "" {
+		w, err := openWAL(filePath, encodeHeader(s.codec), cfg.walSync, cfg.compactBytes, !cfg.loadOnInit)
+		if err != nil {
+			return nil, err
+		}
+		s.wal = w
+		if cfg.loadOnInit {
+			n, foreign, err := replayWAL(w, s.codec, s.keys, s.applyRecord)
+			if err != nil {
+				w.close()
+				return nil, err
+			}
+			// 重放出的变更尚未进入快照
+			if n > 0 {
+				s.dirty.Store(true)
+			}
+			// 现有日志由其它 Codec 写出，立即压缩，避免同一日志混用编码
+			if foreign {
+				if err := s.save(); err != nil {
+					w.close()
+					return nil, err
+				}
+			}
+		}
+	}
+
+	s.rebuildLimiter()
+
 	// 启动后台循环（仅当间隔 > 0）
-	if s.saveInterval > 0 {
+	if s.saveInterval > 0 || s.cleanupInterval > 0 {
 		s.wg.Add(1)
 		go s.backgroundLoop()
 	}
+	if s.fsync != nil && cfg.reloadInterval > 0 {
+		s.wg.Add(1)
+		go s.reloadLoop(cfg.reloadInterval)
+	}
+	if cfg.metrics != nil && cfg.metricsInterval > 0 {
+		s.wg.Add(1)
+		go s.metricsLoop(cfg.metricsInterval, cfg.metrics)
+	}
 
+	started = true
 	return s, nil
 }
 
@@ -112,67 +321,101 @@ func (s *KVStore[V]) Close() error {
 	// 关闭后台
 	close(s.stopCh)
 	s.wg.Wait()
+	s.watch.close()
 	// 强制保存（memory-only 模式下无操作）
-	return s.Save()
+	err := s.Save()
+	if s.metrics != nil {
+		s.metrics(s.Stats())
+	}
+	if s.wal != nil {
+		if cerr := s.wal.close(); err == nil {
+			err = cerr
+		}
+	}
+	if s.flock != nil {
+		if cerr := s.flock.close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
 }
 
 // ---------------------------
 // 基础操作 API
 // ---------------------------
 
-// Set 设置键，不设置过期（覆盖旧值）
+// Set 设置键，不设置过期（覆盖旧值）。
+//
+// 以 NUL（"\x00"）开头的 key 保留给 Bucket：根层面的写入（Set / SetWithTTL / SetNX /
+// SetWithTags / SetEntry / Batch）不会转义这类 key，形如 BucketKey(name, key) 的 key 会写入对应
+// Bucket，其余 NUL 开头的 key 不出现在 Keys / Scan / Watch 中。业务 key 不应以 NUL 开头。
 func (s *KVStore[V]) Set(key string, value V) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.data[key] = item[V]{Value: value, ExpireAt: 0}
-	s.dirty = true
+	sh := s.lock(key)
+	defer s.unlock(sh, key)
+	s.putLocked(sh, key, item[V]{Value: value, ExpireAt: 0})
 }
 
 // SetWithTTL 设置键并设置 ttl（零或负值表示不过期）
 func (s *KVStore[V]) SetWithTTL(key string, value V, ttl time.Duration) {
-	var expireAt int64
-	if ttl > 0 {
-		expireAt = time.Now().Add(ttl).UnixNano()
-	}
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.data[key] = item[V]{Value: value, ExpireAt: expireAt}
-	s.dirty = true
+	it := s.newItem(value, ttl)
+	sh := s.lock(key)
+	defer s.unlock(sh, key)
+	s.putLocked(sh, key, it)
 }
 
 // Get 获取键（惰性过期：如果过期则视为不存在，但不在此处写磁盘）
 // 返回 (zero, false) 当不存在或已过期
 func (s *KVStore[V]) Get(key string) (V, bool) {
-	s.mu.RLock()
-	it, ok := s.data[key]
-	s.mu.RUnlock()
+	sh := s.shardFor(key)
+	sh.mu.RLock()
+	it, ok := sh.data[key]
+	if ok && s.limit != nil {
+		s.limit.policy.access(key)
+	}
+	sh.mu.RUnlock()
 
 	var zero V
 	if !ok {
+		s.stats.misses.Add(1)
 		return zero, false
 	}
 	if it.ExpireAt > 0 && time.Now().UnixNano() > it.ExpireAt {
-		// 已过期，惰性返回不存在
+		// 已过期，惰性删除并返回不存在
+		s.stats.misses.Add(1)
+		s.expireKey(key)
 		return zero, false
 	}
+	s.stats.hits.Add(1)
+	if it.Slide > 0 && s.sliding {
+		s.slide(key)
+	}
 	return it.Value, true
 }
 
+// expireKey 删除已过期的 key（重新加锁后再次确认）
+func (s *KVStore[V]) expireKey(key string) {
+	sh := s.lock(key)
+	defer s.unlock(sh, "")
+	if it, ok := sh.data[key]; ok && it.expired(time.Now().UnixNano()) {
+		s.removeLocked(sh, key, EventExpire)
+	}
+}
+
 // Delete 删除键（如果存在则标记 dirty）
 func (s *KVStore[V]) Delete(key string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	if _, ok := s.data[key]; ok {
-		delete(s.data, key)
-		s.dirty = true
+	sh := s.lock(key)
+	defer s.unlock(sh, "")
+	if _, ok := sh.data[key]; ok {
+		s.removeLocked(sh, key, EventDelete)
 	}
 }
 
 // Exists 判断键是否存在且未过期
 func (s *KVStore[V]) Exists(key string) bool {
-	s.mu.RLock()
-	it, ok := s.data[key]
-	s.mu.RUnlock()
+	sh := s.shardFor(key)
+	sh.mu.RLock()
+	it, ok := sh.data[key]
+	sh.mu.RUnlock()
 	if !ok {
 		return false
 	}
@@ -182,53 +425,147 @@ func (s *KVStore[V]) Exists(key string) bool {
 	return true
 }
 
-// TTL 返回键剩余生存时间。如果不存在或不过期，返回 (0, false) 或 (0, true) 分别表示不存在/不过期
-func (s *KVStore[V]) TTL(key string) (time.Duration, bool) {
-	s.mu.RLock()
-	it, ok := s.data[key]
-	s.mu.RUnlock()
-	if !ok {
-		return 0, false
-	}
-	if it.ExpireAt == 0 {
-		// 存在但不过期
-		return 0, true
-	}
-	now := time.Now().UnixNano()
-	if now > it.ExpireAt {
-		return 0, false
-	}
-	return time.Duration(it.ExpireAt - now), true
-}
-
-// Keys 返回所有未过期的键（顺序不保证）
+// Keys 返回所有未过期的键（顺序不保证，不含 Bucket 内的键）
 func (s *KVStore[V]) Keys() []string {
 	now := time.Now().UnixNano()
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	keys := make([]string, 0, len(s.data))
-	for k, v := range s.data {
-		if v.ExpireAt == 0 || v.ExpireAt > now {
-			keys = append(keys, k)
+	keys := make([]string, 0, s.count.Load())
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for k, v := range sh.data {
+			if (v.ExpireAt == 0 || v.ExpireAt > now) && !isBucketKey(k) {
+				keys = append(keys, k)
+			}
 		}
+		sh.mu.RUnlock()
 	}
 	return keys
 }
 
 // Save 导出到磁盘（对外暴露的保存方法）
 // 如果 filePath == ""（memory-only），则无操作并返回 nil。
+// WAL 模式下 Save 会把当前数据压缩为快照并清空日志。
 func (s *KVStore[V]) Save() error {
 	return s.save()
 }
 
+// Compact 立即清理过期条目并按当前配置重写快照（WAL 模式下同时清空日志），即使没有未保存的修改
+func (s *KVStore[V]) Compact() error {
+	s.cleanupLocked(time.Now().UnixNano())
+	s.dirty.Store(true)
+	return s.save()
+}
+
+// ---------------------------
+// 写入路径（内部，调用方需持有 sh 的写锁）
+// ---------------------------
+
+// putLocked 写入 item 并追加日志；超出容量的淘汰在 unlock 时进行
+func (s *KVStore[V]) putLocked(sh *shard[V], key string, it item[V]) {
+	old, existed := sh.data[key]
+	sh.data[key] = it
+	sh.trackExpiry(key, it.ExpireAt)
+	if !existed {
+		s.count.Add(1)
+	}
+	s.stats.sets.Add(1)
+	s.dirty.Store(true)
+	if s.wal != nil {
+		s.appendWAL(walRecord[V]{Op: walOpSet, Key: key, Item: &it})
+	}
+	if s.changes != nil {
+		s.changes.mark(key)
+	}
+	s.indexPut(key, it.Value)
+	if len(it.Tags) > 0 || len(old.Tags) > 0 {
+		s.tags.set(key, it.Tags)
+	}
+	// 已过期的旧值视为不存在，先按过期通知
+	live := existed && !old.expired(time.Now().UnixNano())
+	if existed && !live {
+		s.emitLocked(sh, Event[V]{Type: EventExpire, Key: key, Old: old.Value, HasOld: true})
+	}
+	s.emitLocked(sh, Event[V]{Type: EventSet, Key: key, Old: old.Value, HasOld: live, New: it.Value})
+	if s.limit != nil {
+		s.limit.track(key, it.Value, existed)
+	}
+}
+
+// removeLocked 删除 key 并追加日志，reason 为对应的事件类型
+func (s *KVStore[V]) removeLocked(sh *shard[V], key string, reason EventType) {
+	old := sh.data[key]
+	delete(sh.data, key)
+	s.count.Add(-1)
+	switch reason {
+	case EventDelete:
+		s.stats.deletes.Add(1)
+	case EventExpire:
+		s.stats.expirations.Add(1)
+	}
+	s.dirty.Store(true)
+	if s.wal != nil {
+		s.appendWAL(walRecord[V]{Op: walOpDelete, Key: key})
+	}
+	if s.changes != nil {
+		s.changes.mark(key)
+	}
+	s.indexRemove(key)
+	if len(old.Tags) > 0 {
+		s.tags.remove(key)
+	}
+	s.emitLocked(sh, Event[V]{Type: reason, Key: key, Old: old.Value, HasOld: true})
+	if s.limit != nil {
+		s.limit.untrack(key)
+	}
+}
+
+// unlock 释放分片写锁、投递事件，并在锁外执行容量淘汰（keep 为本次写入的键，不会被淘汰）
+func (s *KVStore[V]) unlock(sh *shard[V], keep string) {
+	s.release(sh)
+	s.enforceLimit(keep)
+}
+
+// applyRecord 将一条日志记录应用到内存（仅在 load 重放时使用）
+func (s *KVStore[V]) applyRecord(rec walRecord[V]) {
+	switch rec.Op {
+	case walOpSet:
+		if rec.Item != nil {
+			sh := s.lock(rec.Key)
+			if _, ok := sh.data[rec.Key]; !ok {
+				s.count.Add(1)
+			}
+			sh.data[rec.Key] = *rec.Item
+			sh.trackExpiry(rec.Key, rec.Item.ExpireAt)
+			s.tags.set(rec.Key, rec.Item.Tags)
+			sh.mu.Unlock()
+		}
+	case walOpDelete:
+		sh := s.lock(rec.Key)
+		if _, ok := sh.data[rec.Key]; ok {
+			delete(sh.data, rec.Key)
+			s.count.Add(-1)
+			s.tags.remove(rec.Key)
+		}
+		sh.mu.Unlock()
+	case walOpBatch:
+		for _, op := range rec.Ops {
+			s.applyRecord(op)
+		}
+	}
+}
+
 // ---------------------------
 // 持久化实现（内部）
 // ---------------------------
 
 func (s *KVStore[V]) load() error {
+	if s.backend != nil {
+		return s.loadBackend()
+	}
 	if s.filePath == "" {
 		return nil
 	}
+	// 先取文件信息再读取，读取期间的外部修改会在下次检查时发现
+	st, _ := os.Stat(s.filePath)
 	f, err := os.ReadFile(s.filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -239,66 +576,211 @@ func (s *KVStore[V]) load() error {
 	if len(f) == 0 {
 		return nil
 	}
-	tmp := make(map[string]item[V])
-	if err := json.Unmarshal(f, &tmp); err != nil {
-		return err
+	tmp, meta, err := s.decodeFile(f, s.strictCodec)
+	// 主文件损坏时回退到最新的有效备份（配置错误与密钥错误不回退）
+	dirty := false
+	if err != nil {
+		var de *DecryptError
+		if errors.Is(err, ErrCodecMismatch) || errors.As(err, &de) {
+			return err
+		}
+		if tmp, meta, err = s.loadBackup(err); err != nil {
+			return err
+		}
+		dirty = true
+	}
+	s.keyID = meta.keyID
+	if s.fsync != nil && !dirty {
+		s.fsync.record(st, f)
 	}
-	s.mu.Lock()
-	s.data = tmp
-	// load 后认为与磁盘一致，dirty = false
-	s.dirty = false
-	s.mu.Unlock()
+	s.lockAll()
+	s.replaceLocked(tmp)
+	// load 后认为与磁盘一致，dirty = false；若格式与配置不同或来自备份则下次保存时重写
+	s.dirty.Store(dirty || s.stale(meta))
+	s.unlockAll("")
 	return nil
 }
 
+// fileMeta 持久化文件的格式信息
+type fileMeta struct {
+	codec      Codec
+	compressor Compressor // nil 表示未压缩
+	keyID      string     // 空表示未加密
+}
+
+// stale 判断按 meta 格式写出的文件是否需要按当前配置重写
+func (s *KVStore[V]) stale(meta fileMeta) bool {
+	if meta.codec.Name() != s.codec.Name() || compressorName(meta.compressor) != compressorName(s.compressor) {
+		return true
+	}
+	return s.keys != nil && meta.keyID == ""
+}
+
+// fileFormat 持久化文件的编码配置：Codec 编码，按需压缩、加密
+type fileFormat struct {
+	codec      Codec
+	compressor Compressor
+	keys       KeyProvider
+}
+
+func (s *KVStore[V]) format() fileFormat {
+	return fileFormat{codec: s.codec, compressor: s.compressor, keys: s.keys}
+}
+
+// encode 把快照编码为持久化文件内容
+func (f fileFormat) encode(snap any) ([]byte, fileMeta, error) {
+	meta := fileMeta{codec: f.codec, compressor: f.compressor}
+	data, err := encodeWithHeader(f.codec, snap)
+	if err != nil {
+		return nil, meta, err
+	}
+	if f.compressor != nil {
+		if data, err = f.compressor.Compress(data); err != nil {
+			return nil, meta, err
+		}
+	}
+	if f.keys == nil {
+		return data, meta, nil
+	}
+	data, meta.keyID, err = seal(f.keys, data)
+	return data, meta, err
+}
+
+// decodeFile 是 fileFormat.encode 的逆过程，按魔数与文件头识别格式
+func decodeFile[V any](f fileFormat, raw []byte, strict bool) (map[string]item[V], fileMeta, error) {
+	return decodeFileAs[item[V]](f, raw, strict)
+}
+
+// decodeFileAs 与 decodeFile 相同，但解码为任意条目类型 E（如跳过值的 itemMeta）
+func decodeFileAs[E any](f fileFormat, raw []byte, strict bool) (map[string]E, fileMeta, error) {
+	var meta fileMeta
+	plain, keyID, err := unseal(f.keys, raw)
+	if err != nil {
+		return nil, meta, err
+	}
+	meta.keyID = keyID
+	if c := detectCompressor(plain); c != nil {
+		meta.compressor = c
+		if plain, err = c.Decompress(plain); err != nil {
+			return nil, meta, fmt.Errorf("kv: %s decompress: %w", c.Name(), err)
+		}
+	}
+	data, codec, err := decodeSnapshot[E](plain, f.codec, strict)
+	meta.codec = codec
+	return data, meta, err
+}
+
+func (s *KVStore[V]) encodeFile(snap map[string]item[V]) ([]byte, fileMeta, error) {
+	return s.format().encode(snap)
+}
+
+func (s *KVStore[V]) decodeFile(raw []byte, strict bool) (map[string]item[V], fileMeta, error) {
+	return decodeFile[V](s.format(), raw, strict)
+}
+
 // save 在内部执行实际保存（原子写入）
-func (s *KVStore[V]) save() error {
+func (s *KVStore[V]) save() (err error) {
 	// memory-only 模式跳过
-	if s.filePath == "" {
+	if s.filePath == "" && s.backend == nil {
 		return nil
 	}
 
-	s.mu.Lock()
-	// 如果不脏则不保存
-	if !s.dirty {
-		s.mu.Unlock()
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	defer func() {
+		if err != nil {
+			s.stats.saveErrors.Add(1)
+		}
+	}()
+	if s.backend != nil {
+		return s.saveBackend()
+	}
+
+	// 热加载 / 协作模式：即使不脏也要先合并外部修改，避免覆盖
+	if s.fsync != nil {
+		if s.shared() {
+			if err := s.flock.lockSave(); err != nil {
+				return err
+			}
+			defer s.flock.unlockSave()
+		}
+		if err := s.reloadFile(); err != nil {
+			return err
+		}
+	}
+
+	// 如果不脏则不保存（密钥轮换后即使不脏也要重写）
+	if !s.dirty.Load() && !s.keyRotated() {
 		return nil
 	}
 
-	// 在持锁的情况下 Marshal，保证一致性与避免并发 map 读写 panic
-	var data []byte
-	var err error
-	if s.pretty {
-		data, err = json.MarshalIndent(s.data, "", "  ")
-	} else {
-		data, err = json.Marshal(s.data)
+	// 在全部分片的读锁内复制一份快照（仅复制，不编码），写入方只在复制期间等待
+	s.rlockAll()
+	snap := s.snapshotLocked()
+	s.dirty.Store(false)
+	var changed map[string]struct{}
+	if s.changes != nil {
+		changed = s.changes.take()
 	}
-	if err != nil {
-		// 保持 dirty 原样（以便下次重试）
-		s.mu.Unlock()
+
+	// WAL 模式：在持锁状态下轮转日志，保证快照覆盖轮转前的所有记录
+	if s.wal != nil {
+		if err := s.wal.rotate(); err != nil {
+			s.dirty.Store(true)
+			s.runlockAll()
+			return err
+		}
+	}
+	s.runlockAll()
+
+	if err := s.writeSnapshot(snap); err != nil {
+		if changed != nil {
+			s.changes.restore(changed)
+		}
 		return err
 	}
+	return nil
+}
 
-	// reset dirty only after successful marshal to avoid losing changes
-	s.dirty = false
-	s.mu.Unlock()
+// writeSnapshot 在锁外编码并原子写入快照，随后写备份（调用方需持有 saveMu）
+func (s *KVStore[V]) writeSnapshot(snap map[string]item[V]) error {
+	start := time.Now()
+	// 在锁外 Marshal
+	data, meta, err := s.encodeFile(snap)
+	if err != nil {
+		// 恢复 dirty 标记以便下次重试
+		s.dirty.Store(true)
+		return err
+	}
 
 	tmpFile := s.filePath + ".tmp"
 	if err := os.WriteFile(tmpFile, data, 0o644); err != nil {
 		// 写失败，恢复 dirty 标记以便下次重试
-		s.mu.Lock()
-		s.dirty = true
-		s.mu.Unlock()
+		s.dirty.Store(true)
 		return err
 	}
 	// 尝试重命名，覆盖目标文件（原子）
 	if err := os.Rename(tmpFile, s.filePath); err != nil {
 		// 重命名失败也恢复 dirty 标记
-		s.mu.Lock()
-		s.dirty = true
-		s.mu.Unlock()
+		s.dirty.Store(true)
 		return err
 	}
+	s.keyID = meta.keyID
+	s.stats.saved(time.Now(), time.Since(start))
+	if s.fsync != nil {
+		if st, err := os.Stat(s.filePath); err == nil {
+			s.fsync.record(st, data)
+		}
+	}
+	// 快照已落盘，旧日志可以丢弃
+	if s.wal != nil {
+		if err := s.wal.dropRotated(); err != nil {
+			return err
+		}
+	}
+	if err := s.backup.write(data, time.Now()); err != nil {
+		return fmt.Errorf("kv: backup: %w", err)
+	}
 	return nil
 }
 
@@ -308,36 +790,45 @@ func (s *KVStore[V]) save() error {
 
 func (s *KVStore[V]) backgroundLoop() {
 	defer s.wg.Done()
-	ticker := time.NewTicker(s.saveInterval)
-	defer ticker.Stop()
+	var saveC, cleanupC <-chan time.Time
+	if s.saveInterval > 0 {
+		ticker := time.NewTicker(s.saveInterval)
+		defer ticker.Stop()
+		saveC = ticker.C
+	}
+	if s.cleanupInterval > 0 {
+		ticker := time.NewTicker(s.cleanupInterval)
+		defer ticker.Stop()
+		cleanupC = ticker.C
+	}
 
 	for {
 		select {
 		case <-s.stopCh:
 			// 在退出前做一次清理和保存尝试
 			s.cleanupLocked(time.Now().UnixNano())
-			_ = s.save()
+			s.reportError(s.save())
 			return
-		case <-ticker.C:
-			now := time.Now().UnixNano()
-			s.cleanupLocked(now)
-			_ = s.save()
+		case <-cleanupC:
+			s.cleanupLocked(time.Now().UnixNano())
+		case <-saveC:
+			// WAL 模式下写入已持久化，仅在日志过大时压缩
+			if s.wal != nil && !s.wal.needCompact() {
+				continue
+			}
+			s.reportError(s.save())
 		}
 	}
 }
 
-// cleanupLocked 在外部无需加锁的情况下调用（内部会加锁），清理过期项并设置 dirty
+// cleanupLocked 在外部无需加锁的情况下调用（内部逐个分片加锁），清理过期项并设置 dirty。
+// 只处理过期堆中已到期的条目。
 func (s *KVStore[V]) cleanupLocked(now int64) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	changed := false
-	for k, v := range s.data {
-		if v.ExpireAt > 0 && now > v.ExpireAt {
-			delete(s.data, k)
-			changed = true
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		for _, k := range sh.dueLocked(now) {
+			s.removeLocked(sh, k, EventExpire)
 		}
-	}
-	if changed {
-		s.dirty = true
+		s.unlock(sh, "")
 	}
 }
@@ -0,0 +1,633 @@
+// Package kvcli 实现 gkit-kv 命令行工具：查看、编辑、导入导出 kv.KVStore 的持久化文件。
+//
+// 值在命令行与 dump / import 中以 JSON 表示。cmd/gkit-kv 按文件的 Codec 选择值类型（见 Main）；
+// 用 gob / binary 保存结构体的服务可以用自己的值类型构建工具，获得完整的读写能力：
+//
+//	func main() { os.Exit(kvcli.Run[Session](os.Args[1:], os.Stdout, os.Stderr)) }
+package kvcli
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+const usage = `usage: gkit-kv <command> [flags] <file> [args]
+
+commands:
+  get     <file> <key>          print the value of key
+  set     <file> <key> <json>   set key to a JSON value (-ttl, -tag, -sliding)
+  del     <file> <key>...       delete keys
+  ls      <file>                list keys (-prefix, -l)
+  ttl     <file> <key>          show the expiry of key
+  dump    <file>                write all entries as JSON Lines (-expired)
+  import  <file> [input]        read JSON Lines from input (default stdin)
+  compact <file>                drop expired entries, fold the WAL and rewrite the file
+  verify  <file>                decode the file and WAL and print a summary
+
+common flags:
+  -bucket name   operate on keys inside a bucket
+  -key id=hex    encryption key (repeatable, the first one encrypts; default $GKIT_KV_KEYS)
+  -pretty        write indented JSON (set, del, import, compact; indented files stay indented)
+
+Write commands take the store's exclusive file lock and fail if another process holds it.
+`
+
+// ErrReadOnly 当前值类型无法无损写回文件
+var ErrReadOnly = errors.New("kvcli: file is opened read-only")
+
+// exitError 携带退出码的错误
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+
+func notFound(key string) error {
+	return &exitError{code: 1, err: fmt.Errorf("%s: not found", key)}
+}
+
+// Run 执行一条 gkit-kv 命令并返回退出码，V 为存储的值类型
+func Run[V any](args []string, stdout, stderr io.Writer) int {
+	return run[V](args, stdout, stderr, nil)
+}
+
+// Main 是 cmd/gkit-kv 的入口，按文件的 Codec 选择值类型：
+// JSON 文件以原始 JSON 读写（无损）；binary 文件解码为通用值，只读；
+// gob 文件需要值的 Go 类型才能解码，只支持 ls / ttl / verify，完整读写请使用 Run[V] 构建专用工具。
+func Main(args []string, stdout, stderr io.Writer) int {
+	if help(args, stdout) {
+		return 0
+	}
+	inv, err := parse(args)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	_, _, err = kv.ReadFile[json.RawMessage](inv.file, inv.readOptions(kv.WithStrictCodec(true))...)
+	if !errors.Is(err, kv.ErrCodecMismatch) {
+		return run[json.RawMessage](args, stdout, stderr, nil)
+	}
+	_, _, err = kv.ReadFile[any](inv.file, inv.readOptions(kv.WithCodec(kv.BinaryCodec), kv.WithStrictCodec(true))...)
+	if !errors.Is(err, kv.ErrCodecMismatch) {
+		return run(args, stdout, stderr, func(c *cli[any]) { c.readOnly = true })
+	}
+	return run(args, stdout, stderr, func(c *cli[struct{}]) {
+		c.readOnly, c.keysOnly, c.readAll = true, true, kv.ReadKeys
+	})
+}
+
+func run[V any](args []string, stdout, stderr io.Writer, configure func(*cli[V])) int {
+	if help(args, stdout) {
+		return 0
+	}
+	inv, err := parse(args)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	c := &cli[V]{invocation: inv, out: stdout, readAll: kv.ReadFile[V]}
+	if configure != nil {
+		configure(c)
+	}
+	return fail(stderr, c.exec())
+}
+
+func help(args []string, stdout io.Writer) bool {
+	if len(args) == 0 || (args[0] != "help" && args[0] != "-h" && args[0] != "-help" && args[0] != "--help") {
+		return false
+	}
+	fmt.Fprint(stdout, usage)
+	return true
+}
+
+func fail(stderr io.Writer, err error) int {
+	if err == nil {
+		return 0
+	}
+	var ee *exitError
+	if !errors.As(err, &ee) {
+		ee = &exitError{code: 1, err: err}
+	}
+	fmt.Fprintln(stderr, "gkit-kv:", ee.err)
+	if ee.code == 2 {
+		fmt.Fprint(stderr, usage)
+	}
+	return ee.code
+}
+
+// ---------------------------
+// 参数解析
+// ---------------------------
+
+type invocation struct {
+	cmd  string
+	file string
+	args []string
+
+	bucket  string
+	keyIDs  []string
+	keys    map[string][]byte
+	prefix  string
+	long    bool
+	ttl     time.Duration
+	sliding bool
+	tags    []string
+	expired bool
+	pretty  bool
+}
+
+// argCount 各命令 <file> 之后的参数个数（-1 表示至少一个，-2 表示零或一个）
+var argCount = map[string]int{
+	"get": 1, "set": 2, "del": -1, "ls": 0, "ttl": 1,
+	"dump": 0, "import": -2, "compact": 0, "verify": 0,
+}
+
+func usageError(format string, a ...any) error {
+	return &exitError{code: 2, err: fmt.Errorf(format, a...)}
+}
+
+func parse(args []string) (*invocation, error) {
+	if len(args) == 0 {
+		return nil, usageError("missing command")
+	}
+	inv := &invocation{cmd: args[0], keys: make(map[string][]byte)}
+	want, ok := argCount[inv.cmd]
+	if !ok {
+		return nil, usageError("unknown command %q", inv.cmd)
+	}
+
+	fs := flag.NewFlagSet(inv.cmd, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&inv.bucket, "bucket", "", "")
+	fs.Func("key", "", inv.addKey)
+	switch inv.cmd {
+	case "ls":
+		fs.StringVar(&inv.prefix, "prefix", "", "")
+		fs.BoolVar(&inv.long, "l", false, "")
+	case "set":
+		fs.DurationVar(&inv.ttl, "ttl", 0, "")
+		fs.BoolVar(&inv.sliding, "sliding", false, "")
+		fs.Func("tag", "", func(v string) error {
+			inv.tags = append(inv.tags, v)
+			return nil
+		})
+	case "dump":
+		fs.BoolVar(&inv.expired, "expired", false, "")
+	}
+	if inv.cmd == "set" || inv.cmd == "del" || inv.cmd == "import" || inv.cmd == "compact" {
+		fs.BoolVar(&inv.pretty, "pretty", false, "")
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return nil, usageError("%s: %v", inv.cmd, err)
+	}
+
+	rest := fs.Args()
+	if len(rest) == 0 {
+		return nil, usageError("%s: missing file", inv.cmd)
+	}
+	inv.file, inv.args = rest[0], rest[1:]
+	switch n := len(inv.args); {
+	case want >= 0 && n != want,
+		want == -1 && n == 0,
+		want == -2 && n > 1:
+		return nil, usageError("%s: wrong number of arguments", inv.cmd)
+	}
+
+	if len(inv.keyIDs) == 0 {
+		if env := os.Getenv("GKIT_KV_KEYS"); env != "" {
+			for _, kv := range strings.Split(env, ",") {
+				if err := inv.addKey(strings.TrimSpace(kv)); err != nil {
+					return nil, usageError("GKIT_KV_KEYS: %v", err)
+				}
+			}
+		}
+	}
+	return inv, nil
+}
+
+// addKey 解析 id=hex 形式的密钥
+func (inv *invocation) addKey(v string) error {
+	id, h, ok := strings.Cut(v, "=")
+	if !ok || id == "" {
+		return fmt.Errorf("key must be id=hex, got %q", v)
+	}
+	key, err := hex.DecodeString(h)
+	if err != nil {
+		return fmt.Errorf("key %q: %v", id, err)
+	}
+	if _, dup := inv.keys[id]; !dup {
+		inv.keyIDs = append(inv.keyIDs, id)
+	}
+	inv.keys[id] = key
+	return nil
+}
+
+func (inv *invocation) readOptions(opts ...kv.Option) []kv.Option {
+	if len(inv.keyIDs) > 0 {
+		opts = append(opts, kv.WithEncryption(kv.StaticKeys(inv.keyIDs[0], inv.keys)))
+	}
+	return opts
+}
+
+// internal 返回命令行中的 key 在存储中的形式
+func (inv *invocation) internal(key string) string {
+	if inv.bucket == "" {
+		return key
+	}
+	return kv.BucketKey(inv.bucket, key)
+}
+
+// ---------------------------
+// 命令
+// ---------------------------
+
+type cli[V any] struct {
+	*invocation
+	out      io.Writer
+	readOnly bool
+	// keysOnly 只解码键与元数据（值类型未知），不能输出或写入值
+	keysOnly bool
+	readAll  func(filePath string, opts ...kv.Option) (map[string]kv.Entry[V], kv.FileInfo, error)
+}
+
+func (c *cli[V]) exec() error {
+	if c.keysOnly && c.cmd != "ls" && c.cmd != "ttl" && c.cmd != "verify" {
+		return fmt.Errorf("%s: %s needs the values' Go type to decode; build a tool with kvcli.Run[YourType]", c.file, c.cmd)
+	}
+	switch c.cmd {
+	case "get":
+		return c.get()
+	case "set":
+		return c.set()
+	case "del":
+		return c.del()
+	case "ls":
+		return c.ls()
+	case "ttl":
+		return c.ttlCmd()
+	case "dump":
+		return c.dump()
+	case "import":
+		return c.importCmd()
+	case "compact":
+		return c.compact()
+	case "verify":
+		return c.verify()
+	}
+	return usageError("unknown command %q", c.cmd)
+}
+
+// read 只读地加载文件，不加锁、不修改任何文件
+func (c *cli[V]) read() (map[string]kv.Entry[V], kv.FileInfo, error) {
+	return c.readAll(c.file, c.readOptions()...)
+}
+
+// open 以文件现有的格式打开存储，持有独占文件锁直到 Close
+func (c *cli[V]) open() (*kv.KVStore[V], error) {
+	if c.readOnly {
+		return nil, ErrReadOnly
+	}
+	_, info, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+	opts := c.readOptions(kv.WithSaveInterval(0), kv.WithFileLock(kv.LockExclusive))
+	if info.Codec != nil && info.Codec.Name() != kv.JSONCodec.Name() {
+		opts = append(opts, kv.WithCodec(info.Codec))
+	} else {
+		// JSON 由 WithPrettyJSON 决定格式，已缩进的文件改写后保持缩进
+		opts = append(opts, kv.WithPrettyJSON(c.pretty || indented(c.file)))
+	}
+	if info.Compressor != nil {
+		opts = append(opts, kv.WithCompression(info.Compressor))
+	}
+	if info.WAL {
+		opts = append(opts, kv.WithWAL(true))
+	}
+	store, err := kv.NewKVStore[V](c.file, opts...)
+	if errors.Is(err, kv.ErrLocked) {
+		return nil, fmt.Errorf("%s is locked by another process", c.file)
+	}
+	return store, err
+}
+
+// indented 判断 JSON 文件是否为缩进格式（压缩或加密的文件视为否）
+func indented(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	var head [2]byte
+	_, err = io.ReadFull(f, head[:])
+	return err == nil && string(head[:]) == "{\n"
+}
+
+// live 返回未过期的条目
+func live[V any](entries map[string]kv.Entry[V], now time.Time) map[string]kv.Entry[V] {
+	for k, e := range entries {
+		if !e.ExpireAt.IsZero() && now.After(e.ExpireAt) {
+			delete(entries, k)
+		}
+	}
+	return entries
+}
+
+func (c *cli[V]) get() error {
+	entries, _, err := c.read()
+	if err != nil {
+		return err
+	}
+	e, ok := live(entries, time.Now())[c.internal(c.args[0])]
+	if !ok {
+		return notFound(c.args[0])
+	}
+	return c.printJSON(e.Value)
+}
+
+func (c *cli[V]) set() error {
+	var v V
+	if err := json.Unmarshal([]byte(c.args[1]), &v); err != nil {
+		return fmt.Errorf("value: %w", err)
+	}
+	e := kv.Entry[V]{Value: v}
+	if c.ttl > 0 {
+		e.ExpireAt = time.Now().Add(c.ttl)
+		if c.sliding {
+			e.Sliding = c.ttl
+		}
+	}
+	if len(c.tags) > 0 {
+		e.Tags = slices.Compact(slices.Sorted(slices.Values(c.tags)))
+	}
+
+	store, err := c.open()
+	if err != nil {
+		return err
+	}
+	store.SetEntry(c.internal(c.args[0]), e)
+	return store.Close()
+}
+
+func (c *cli[V]) del() error {
+	store, err := c.open()
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, key := range c.args {
+		if store.Exists(c.internal(key)) {
+			n++
+		}
+		store.Delete(c.internal(key))
+	}
+	if err := store.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "deleted %d\n", n)
+	return nil
+}
+
+func (c *cli[V]) ls() error {
+	entries, _, err := c.read()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var keys []string
+	for k := range live(entries, now) {
+		bucket, key, inBucket := kv.ParseBucketKey(k)
+		if bucket != c.bucket || inBucket != (c.bucket != "") || !strings.HasPrefix(key, c.prefix) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		_, key, _ := kv.ParseBucketKey(k)
+		if !c.long {
+			fmt.Fprintln(c.out, key)
+			continue
+		}
+		e := entries[k]
+		fmt.Fprintf(c.out, "%s\t%s\t%s\n", key, describeTTL(e, now), strings.Join(e.Tags, ","))
+	}
+	return nil
+}
+
+func (c *cli[V]) ttlCmd() error {
+	entries, _, err := c.read()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	e, ok := live(entries, now)[c.internal(c.args[0])]
+	if !ok {
+		return notFound(c.args[0])
+	}
+	fmt.Fprintln(c.out, describeTTL(e, now))
+	return nil
+}
+
+func describeTTL[V any](e kv.Entry[V], now time.Time) string {
+	if e.ExpireAt.IsZero() {
+		return "no expiry"
+	}
+	s := fmt.Sprintf("%s (%s)", e.ExpireAt.Sub(now).Round(time.Second), e.ExpireAt.UTC().Format(time.RFC3339))
+	if e.Sliding > 0 {
+		s += ", sliding " + e.Sliding.String()
+	}
+	return s
+}
+
+// record dump / import 的一行
+type record struct {
+	Bucket   string          `json:"bucket,omitempty"`
+	Key      string          `json:"key"`
+	Value    json.RawMessage `json:"value"`
+	ExpireAt *time.Time      `json:"expire_at,omitempty"`
+	Sliding  string          `json:"sliding,omitempty"`
+	Tags     []string        `json:"tags,omitempty"`
+}
+
+func (c *cli[V]) dump() error {
+	entries, _, err := c.read()
+	if err != nil {
+		return err
+	}
+	if !c.expired {
+		entries = live(entries, time.Now())
+	}
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	w := bufio.NewWriter(c.out)
+	enc := json.NewEncoder(w)
+	for _, k := range keys {
+		e := entries[k]
+		value, err := json.Marshal(e.Value)
+		if err != nil {
+			return fmt.Errorf("%q: %w", k, err)
+		}
+		rec := record{Value: value, Tags: e.Tags}
+		rec.Bucket, rec.Key, _ = kv.ParseBucketKey(k)
+		if !e.ExpireAt.IsZero() {
+			at := e.ExpireAt.UTC()
+			rec.ExpireAt = &at
+		}
+		if e.Sliding > 0 {
+			rec.Sliding = e.Sliding.String()
+		}
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func (c *cli[V]) importCmd() error {
+	in := io.Reader(os.Stdin)
+	if len(c.args) == 1 && c.args[0] != "-" {
+		f, err := os.Open(c.args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	// 先完整解析输入，出错时不修改文件
+	type entry struct {
+		key string
+		e   kv.Entry[V]
+	}
+	var batch []entry
+	sc := bufio.NewScanner(in)
+	sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(strings.TrimSpace(sc.Text())) == 0 {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		var e kv.Entry[V]
+		if err := json.Unmarshal(rec.Value, &e.Value); err != nil {
+			return fmt.Errorf("line %d: value: %w", line, err)
+		}
+		if rec.ExpireAt != nil {
+			e.ExpireAt = *rec.ExpireAt
+		}
+		if rec.Sliding != "" {
+			d, err := time.ParseDuration(rec.Sliding)
+			if err != nil {
+				return fmt.Errorf("line %d: sliding: %w", line, err)
+			}
+			e.Sliding = d
+		}
+		e.Tags = rec.Tags
+		key := rec.Key
+		if rec.Bucket != "" {
+			key = kv.BucketKey(rec.Bucket, rec.Key)
+		} else if c.bucket != "" {
+			key = c.internal(rec.Key)
+		}
+		batch = append(batch, entry{key, e})
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	store, err := c.open()
+	if err != nil {
+		return err
+	}
+	for _, b := range batch {
+		store.SetEntry(b.key, b.e)
+	}
+	if err := store.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "imported %d\n", len(batch))
+	return nil
+}
+
+func (c *cli[V]) compact() error {
+	store, err := c.open()
+	if err != nil {
+		return err
+	}
+	if err := store.Compact(); err != nil {
+		store.Close()
+		return err
+	}
+	n := store.Stats().Keys
+	if err := store.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "compacted %s: %d entries\n", c.file, n)
+	return nil
+}
+
+func (c *cli[V]) verify() error {
+	if _, err := os.Stat(c.file); err != nil {
+		return err
+	}
+	entries, info, err := c.read()
+	if err != nil {
+		return err
+	}
+	total := len(entries)
+	expired := total - len(live(entries, time.Now()))
+
+	codec := "json"
+	if info.Codec != nil {
+		codec = info.Codec.Name()
+	}
+	compression := "none"
+	if info.Compressor != nil {
+		compression = info.Compressor.Name()
+	}
+	encryption := "none"
+	if info.KeyID != "" {
+		encryption = fmt.Sprintf("key %q", info.KeyID)
+	}
+	wal := "none"
+	if info.WAL {
+		wal = fmt.Sprintf("%d records", info.WALRecords)
+	}
+	fmt.Fprintf(c.out, "file:        %s\n", c.file)
+	fmt.Fprintf(c.out, "codec:       %s\n", codec)
+	fmt.Fprintf(c.out, "compression: %s\n", compression)
+	fmt.Fprintf(c.out, "encryption:  %s\n", encryption)
+	fmt.Fprintf(c.out, "entries:     %d (%d expired)\n", total, expired)
+	fmt.Fprintf(c.out, "wal:         %s\n", wal)
+	if info.WALCorrupt > 0 {
+		// 残缺的尾部在下次打开时被截断，通常是写入中途崩溃留下的
+		fmt.Fprintf(c.out, "warning:     %d trailing bytes in the WAL are incomplete or corrupt\n", info.WALCorrupt)
+	}
+	return nil
+}
+
+func (c *cli[V]) printJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.out, "%s\n", data)
+	return err
+}
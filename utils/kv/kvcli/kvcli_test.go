@@ -0,0 +1,238 @@
+package kvcli_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+	"github.com/Yuelioi/gkit/utils/kv/kvcli"
+)
+
+type session struct {
+	User string
+	At   time.Time
+}
+
+func run(t *testing.T, main func([]string, *bytes.Buffer, *bytes.Buffer) int, args ...string) (string, string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := main(args, &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+func generic(args []string, stdout, stderr *bytes.Buffer) int {
+	return kvcli.Main(args, stdout, stderr)
+}
+
+func typed(args []string, stdout, stderr *bytes.Buffer) int {
+	return kvcli.Run[session](args, stdout, stderr)
+}
+
+func TestMain_JSONRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "store.json")
+
+	if _, errOut, code := run(t, generic, "set", "-ttl", "1h", "-tag", "u1", path, "a", `{"n":12345678901234567890}`); code != 0 {
+		t.Fatalf("set failed: %s", errOut)
+	}
+	run(t, generic, "set", "-bucket", "b", path, "x", `"in bucket"`)
+	run(t, generic, "set", path, "plain", `1`)
+
+	// 大整数原样保留
+	if out, _, _ := run(t, generic, "get", path, "a"); out != "{\"n\":12345678901234567890}\n" {
+		t.Fatalf("unexpected get output %q", out)
+	}
+	if out, _, _ := run(t, generic, "ls", path); out != "a\nplain\n" {
+		t.Fatalf("expected root keys only, got %q", out)
+	}
+	if out, _, _ := run(t, generic, "ls", "-bucket", "b", path); out != "x\n" {
+		t.Fatalf("expected bucket keys, got %q", out)
+	}
+	if out, _, _ := run(t, generic, "ttl", path, "plain"); out != "no expiry\n" {
+		t.Fatalf("unexpected ttl %q", out)
+	}
+	if _, _, code := run(t, generic, "get", path, "missing"); code != 1 {
+		t.Fatalf("expected exit 1 for missing key, got %d", code)
+	}
+
+	// 文件可以被 KVStore 直接读取
+	store, err := kv.NewKVStore[json.RawMessage](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags := store.Tags("a"); len(tags) != 1 || tags[0] != "u1" {
+		t.Fatalf("expected tag persisted, got %v", tags)
+	}
+	if _, ok := store.Bucket("b").Get("x"); !ok {
+		t.Fatal("expected bucket key written")
+	}
+	store.Close()
+
+	dump, _, code := run(t, generic, "dump", path)
+	if code != 0 || strings.Count(dump, "\n") != 3 {
+		t.Fatalf("unexpected dump %q", dump)
+	}
+	input := filepath.Join(dir, "dump.jsonl")
+	os.WriteFile(input, []byte(dump), 0o644)
+
+	copyPath := filepath.Join(dir, "copy.json")
+	if out, errOut, code := run(t, generic, "import", copyPath, input); code != 0 || out != "imported 3\n" {
+		t.Fatalf("import failed: %q %q", out, errOut)
+	}
+	if again, _, _ := run(t, generic, "dump", copyPath); again != dump {
+		t.Fatalf("dump after import differs:\n%s\n%s", dump, again)
+	}
+
+	if out, _, _ := run(t, generic, "del", path, "a", "missing"); out != "deleted 1\n" {
+		t.Fatalf("unexpected del output %q", out)
+	}
+	if out, _, _ := run(t, generic, "verify", path); !strings.Contains(out, "entries:     2 (0 expired)") {
+		t.Fatalf("unexpected verify output %q", out)
+	}
+}
+
+func TestRun_TypedBinaryEncrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	key := make([]byte, 32)
+	keys := kv.StaticKeys("k1", map[string][]byte{"k1": key})
+
+	store, err := kv.NewKVStore[session](path, kv.WithCodec(kv.BinaryCodec), kv.WithCompression(kv.GzipCompressor),
+		kv.WithEncryption(keys), kv.WithWAL(true), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	store.Set("s1", session{User: "alice", At: at})
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	store.SetWithTTL("s2", session{User: "bob"}, time.Millisecond)
+	store.Close()
+	time.Sleep(5 * time.Millisecond)
+
+	flagKey := "k1=" + hex.EncodeToString(key)
+	if _, errOut, code := run(t, typed, "get", path, "s1"); code != 1 || !strings.Contains(errOut, "key") {
+		t.Fatalf("expected decrypt error without key, got %d %q", code, errOut)
+	}
+	out, errOut, code := run(t, typed, "verify", "-key", flagKey, path)
+	if code != 0 {
+		t.Fatal(errOut)
+	}
+	for _, want := range []string{"codec:       binary", "compression: gzip", `encryption:  key "k1"`, "entries:     2 (1 expired)"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("verify output missing %q:\n%s", want, out)
+		}
+	}
+
+	if _, errOut, code := run(t, typed, "set", "-key", flagKey, path, "s3", `{"User":"carol"}`); code != 0 {
+		t.Fatal(errOut)
+	}
+	if out, errOut, code := run(t, typed, "compact", "-key", flagKey, path); code != 0 || !strings.Contains(out, "2 entries") {
+		t.Fatalf("compact: %q %q", out, errOut)
+	}
+
+	// 写入保持原有格式
+	entries, info, err := kv.ReadFile[session](path, kv.WithEncryption(keys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Codec.Name() != "binary" || info.Compressor == nil || info.KeyID != "k1" || info.WALRecords != 0 {
+		t.Fatalf("expected format kept and wal folded, got %+v", info)
+	}
+	if len(entries) != 2 || !entries["s1"].Value.At.Equal(at) || entries["s3"].Value.User != "carol" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+
+	// 通用入口：binary 文件只读
+	if out, _, code := run(t, generic, "get", "-key", flagKey, path, "s1"); code != 0 || !strings.Contains(out, "alice") {
+		t.Fatalf("expected generic read of binary file, got %d %q", code, out)
+	}
+	if _, errOut, code := run(t, generic, "del", "-key", flagKey, path, "s1"); code != 1 || !strings.Contains(errOut, "read-only") {
+		t.Fatalf("expected read-only error, got %d %q", code, errOut)
+	}
+}
+
+func TestMain_GobNeedsType(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.gob")
+	store, err := kv.NewKVStore[session](path, kv.WithCodec(kv.GobCodec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set("s1", session{User: "alice"})
+	store.Close()
+
+	if _, errOut, code := run(t, generic, "get", path, "s1"); code != 1 || !strings.Contains(errOut, "kvcli.Run") {
+		t.Fatalf("expected hint to build a typed tool, got %d %q", code, errOut)
+	}
+	if out, _, code := run(t, typed, "get", path, "s1"); code != 0 || !strings.Contains(out, "alice") {
+		t.Fatalf("expected typed read of gob file, got %d %q", code, out)
+	}
+
+	// 键与元数据不需要值类型
+	if out, errOut, code := run(t, generic, "ls", path); code != 0 || out != "s1\n" {
+		t.Fatalf("expected ls of gob file, got %d %q %q", code, out, errOut)
+	}
+	if out, _, code := run(t, generic, "verify", path); code != 0 || !strings.Contains(out, "codec:       gob") {
+		t.Fatalf("expected verify of gob file, got %d %q", code, out)
+	}
+	if _, errOut, code := run(t, generic, "del", path, "s1"); code != 1 || !strings.Contains(errOut, "kvcli.Run") {
+		t.Fatalf("expected hint for write, got %d %q", code, errOut)
+	}
+}
+
+func TestMain_PrettyJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	if _, errOut, code := run(t, generic, "set", "-pretty", path, "a", "1"); code != 0 {
+		t.Fatalf("set failed: %q", errOut)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "{\n") {
+		t.Fatalf("expected indented file, got %q", data)
+	}
+
+	// 不带 -pretty 改写时保持原有缩进
+	if _, errOut, code := run(t, generic, "set", path, "b", "2"); code != 0 {
+		t.Fatalf("set failed: %q", errOut)
+	}
+	data, _ = os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "{\n") || !strings.Contains(string(data), `"b"`) {
+		t.Fatalf("expected indentation kept, got %q", data)
+	}
+}
+
+func TestRun_RespectsFileLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	store, err := kv.NewKVStore[session](path, kv.WithFileLock(kv.LockExclusive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.Set("s1", session{User: "alice"})
+	store.Save()
+
+	if _, errOut, code := run(t, typed, "del", path, "s1"); code != 1 || !strings.Contains(errOut, "locked") {
+		t.Fatalf("expected lock error, got %d %q", code, errOut)
+	}
+	// 只读命令不需要锁
+	if out, _, code := run(t, typed, "ls", path); code != 0 || out != "s1\n" {
+		t.Fatalf("expected read while locked, got %d %q", code, out)
+	}
+}
+
+func TestRun_Usage(t *testing.T) {
+	if _, _, code := run(t, generic); code != 2 {
+		t.Fatalf("expected usage exit code, got %d", code)
+	}
+	if _, _, code := run(t, generic, "get", "file"); code != 2 {
+		t.Fatalf("expected usage error for missing key, got %d", code)
+	}
+	if out, _, code := run(t, generic, "help"); code != 0 || !strings.Contains(out, "commands:") {
+		t.Fatalf("expected help, got %d %q", code, out)
+	}
+}
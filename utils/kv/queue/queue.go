@@ -0,0 +1,498 @@
+// Package queue 基于 kv.KVStore 持久化的轻量任务队列（邮件、Webhook 等），无需额外的基础设施。
+//
+// 任务保存在 KVStore 的 Bucket 中，进程重启后继续执行；语义为至少一次（at-least-once）：
+//   - 延迟执行：Enqueue 时用 Delay / At 指定最早执行时间
+//   - 工作池：WithWorkers 个 goroutine 并发执行 Handler
+//   - 可见性超时：任务被取走后在超时前不会再次分发；Handler 的 ctx 在超时时取消，
+//     进程在执行中崩溃时，任务在超时后重新执行
+//   - 重试与退避：Handler 返回错误后按退避时间重试，超过最大次数移入死信 Bucket
+//   - 优雅关闭：Close 停止分发新任务并等待执行中的任务完成
+//
+// 同一个 KVStore 只能由一个进程中的一个 Queue 消费；建议打开 kv.WithWAL(true) 以免崩溃时丢失最近的状态。
+// 待执行的任务按到期时间保存在内存堆中（New 时从 store 重建），因此入队也要经过消费它的 Queue：
+// 绕过它直接写入 Bucket，或在同一个 store 上另建 Queue 入队的任务，要到下次创建 Queue 时才会被分发。
+package queue
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+var (
+	// ErrDuplicate Enqueue 时同 ID 的任务已在队列中
+	ErrDuplicate = errors.New("queue: duplicate job id")
+	// ErrClosed 队列已关闭
+	ErrClosed = errors.New("queue: closed")
+	// ErrNotDead Requeue 的任务不在死信中
+	ErrNotDead = errors.New("queue: job not in dead letters")
+)
+
+// Job 队列中的任务
+type Job[T any] struct {
+	ID        string    `json:"id"`
+	Payload   T         `json:"payload"`
+	Attempts  int       `json:"attempts"`             // 已开始执行的次数（含本次）
+	RunAt     time.Time `json:"run_at"`               // 最早可执行时间；执行中为可见性超时的截止时间
+	CreatedAt time.Time `json:"created_at"`           // 入队时间
+	LastError string    `json:"last_error,omitempty"` // 最近一次失败的错误信息
+}
+
+// Handler 处理任务，返回错误时按退避重试。ctx 在可见性超时或 Close 的等待超时后取消。
+type Handler[T any] func(ctx context.Context, job Job[T]) error
+
+// Option 定义配置函数类型
+type Option func(*config)
+
+type config struct {
+	name         string
+	workers      int
+	visibility   time.Duration
+	maxAttempts  int
+	backoff      func(attempt int) time.Duration
+	pollInterval time.Duration
+	drainTimeout time.Duration
+}
+
+// WithName 设置队列名（默认 "queue"），任务保存在同名 Bucket，死信保存在 name+":dead"
+func WithName(name string) Option {
+	return func(c *config) { c.name = name }
+}
+
+// WithWorkers 设置并发执行的 worker 数（默认 1）
+func WithWorkers(n int) Option {
+	return func(c *config) { c.workers = n }
+}
+
+// WithVisibilityTimeout 设置可见性超时（默认 30s）
+func WithVisibilityTimeout(d time.Duration) Option {
+	return func(c *config) { c.visibility = d }
+}
+
+// WithMaxAttempts 设置最大执行次数（默认 5），用尽后移入死信
+func WithMaxAttempts(n int) Option {
+	return func(c *config) { c.maxAttempts = n }
+}
+
+// WithBackoff 设置第 attempt 次失败后的重试等待（默认 ExponentialBackoff(time.Second, time.Hour)）
+func WithBackoff(fn func(attempt int) time.Duration) Option {
+	return func(c *config) { c.backoff = fn }
+}
+
+// WithPollInterval 设置检查到期任务的最长间隔（默认 1s）；Enqueue 与任务完成时会立即检查
+func WithPollInterval(d time.Duration) Option {
+	return func(c *config) { c.pollInterval = d }
+}
+
+// WithDrainTimeout 设置 Close 等待执行中任务的时间（默认 0 一直等待）。
+// 超时后取消 Handler 的 ctx，因此中断的任务不计入执行次数，下次启动时立即重新执行。
+func WithDrainTimeout(d time.Duration) Option {
+	return func(c *config) { c.drainTimeout = d }
+}
+
+// ExponentialBackoff 返回 base * 2^(attempt-1) 的退避函数，不超过 max
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		return min(d, max)
+	}
+}
+
+// EnqueueOption 配置单个任务
+type EnqueueOption func(*enqueueOptions)
+
+type enqueueOptions struct {
+	id    string
+	runAt time.Time
+}
+
+// Delay 延迟 d 后执行
+func Delay(d time.Duration) EnqueueOption {
+	return func(o *enqueueOptions) { o.runAt = time.Now().Add(d) }
+}
+
+// At 在 t 之后执行
+func At(t time.Time) EnqueueOption {
+	return func(o *enqueueOptions) { o.runAt = t }
+}
+
+// ID 指定任务 ID（用于去重），同 ID 的任务仍在队列中时 Enqueue 返回 ErrDuplicate
+func ID(id string) EnqueueOption {
+	return func(o *enqueueOptions) { o.id = id }
+}
+
+// Queue 持久化任务队列
+type Queue[T any] struct {
+	store   *kv.KVStore[Job[T]]
+	jobs    *kv.Bucket[Job[T]]
+	dead    *kv.Bucket[Job[T]]
+	handler Handler[T]
+	cfg     config
+
+	mu       sync.Mutex // 串行化任务状态的读改写
+	inflight map[string]struct{}
+	pending  schedule // 未在执行中的任务，按到期时间排序
+	seq      atomic.Uint64
+	closed   atomic.Bool
+
+	wake chan struct{}
+	stop chan struct{}
+	// 执行中任务的父 ctx，Close 等待超时后取消
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	once    sync.Once
+	loop    sync.WaitGroup
+	running sync.WaitGroup
+	slots   chan struct{}
+}
+
+// New 在 store 上创建队列。handler 为 nil 时只能 Enqueue（生产者进程），不执行任务。
+func New[T any](store *kv.KVStore[Job[T]], handler Handler[T], opts ...Option) *Queue[T] {
+	// 默认值
+	cfg := config{
+		name:         "queue",
+		workers:      1,
+		visibility:   30 * time.Second,
+		maxAttempts:  5,
+		backoff:      ExponentialBackoff(time.Second, time.Hour),
+		pollInterval: time.Second,
+	}
+
+	// 应用 Option
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	cfg.workers = max(cfg.workers, 1)
+	cfg.maxAttempts = max(cfg.maxAttempts, 1)
+
+	q := &Queue[T]{
+		store:    store,
+		jobs:     store.Bucket(cfg.name),
+		dead:     store.Bucket(cfg.name + ":dead"),
+		handler:  handler,
+		cfg:      cfg,
+		inflight: make(map[string]struct{}),
+		pending:  schedule{index: make(map[string]*scheduled)},
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		slots:    make(chan struct{}, cfg.workers),
+	}
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+	// 上次运行遗留的任务（含执行中崩溃的，其 RunAt 为可见性超时的截止时间）
+	for id, job := range q.jobs.Scan("") {
+		q.pending.set(id, job.RunAt)
+	}
+	if handler != nil {
+		q.loop.Add(1)
+		go q.dispatchLoop()
+	}
+	return q
+}
+
+// Enqueue 加入任务并返回任务 ID
+func (q *Queue[T]) Enqueue(payload T, opts ...EnqueueOption) (string, error) {
+	if q.closed.Load() {
+		return "", ErrClosed
+	}
+	now := time.Now()
+	o := enqueueOptions{runAt: now}
+	for _, fn := range opts {
+		fn(&o)
+	}
+	if o.id == "" {
+		// 按时间有序，便于按入队顺序执行
+		o.id = fmt.Sprintf("%016x%06x", now.UnixNano(), q.seq.Add(1)&0xffffff)
+	}
+
+	q.mu.Lock()
+	if q.jobs.Exists(o.id) {
+		q.mu.Unlock()
+		return "", ErrDuplicate
+	}
+	q.jobs.Set(o.id, Job[T]{ID: o.id, Payload: payload, RunAt: o.runAt, CreatedAt: now})
+	q.pending.set(o.id, o.runAt)
+	q.mu.Unlock()
+	q.notify()
+	return o.id, nil
+}
+
+// Get 返回队列中（含执行中）的任务
+func (q *Queue[T]) Get(id string) (Job[T], bool) {
+	return q.jobs.Get(id)
+}
+
+// Len 返回队列中（含延迟与执行中）的任务数
+func (q *Queue[T]) Len() int {
+	return q.jobs.Len()
+}
+
+// Cancel 移除尚未开始执行的任务，任务不存在或正在执行时返回 false
+func (q *Queue[T]) Cancel(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, running := q.inflight[id]; running || !q.jobs.Exists(id) {
+		return false
+	}
+	q.jobs.Delete(id)
+	q.pending.remove(id)
+	return true
+}
+
+// DeadLetters 返回死信中的任务（按 ID 排序）
+func (q *Queue[T]) DeadLetters() []Job[T] {
+	var jobs []Job[T]
+	for _, job := range q.dead.Scan("") {
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// Requeue 把死信中的任务放回队列立即执行，执行次数清零
+func (q *Queue[T]) Requeue(id string) error {
+	q.mu.Lock()
+	job, ok := q.dead.Get(id)
+	if !ok {
+		q.mu.Unlock()
+		return ErrNotDead
+	}
+	job.Attempts = 0
+	job.RunAt = time.Now()
+	q.move(q.dead, q.jobs, job)
+	q.pending.set(id, job.RunAt)
+	q.mu.Unlock()
+	q.notify()
+	return nil
+}
+
+// Close 停止分发新任务，等待执行中的任务完成（见 WithDrainTimeout）并保存 store。
+// 不会关闭 store；未执行的任务保留在 store 中，下次创建 Queue 后继续执行。
+func (q *Queue[T]) Close() error {
+	q.once.Do(func() {
+		q.closed.Store(true)
+		close(q.stop)
+		q.loop.Wait()
+
+		done := make(chan struct{})
+		go func() {
+			q.running.Wait()
+			close(done)
+		}()
+		if q.cfg.drainTimeout > 0 {
+			select {
+			case <-done:
+			case <-time.After(q.cfg.drainTimeout):
+				q.cancel()
+				<-done
+			}
+		} else {
+			<-done
+		}
+		q.cancel()
+	})
+	return q.store.Save()
+}
+
+func (q *Queue[T]) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// ---------------------------
+// 分发与执行
+// ---------------------------
+
+func (q *Queue[T]) dispatchLoop() {
+	defer q.loop.Done()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-q.wake:
+		case <-timer.C:
+		}
+		next := q.dispatch()
+		timer.Stop()
+		// 等待下一个任务到期，最长 pollInterval
+		timer.Reset(min(max(time.Until(next), 0), q.cfg.pollInterval))
+	}
+}
+
+// dispatch 把到期的任务分发给空闲的 worker，返回下一个任务的到期时间
+func (q *Queue[T]) dispatch() time.Time {
+	now := time.Now()
+	next := now.Add(q.cfg.pollInterval)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		id, runAt, ok := q.pending.peek()
+		if !ok {
+			return next
+		}
+		if runAt.After(now) {
+			return runAt
+		}
+		select {
+		case q.slots <- struct{}{}:
+		default:
+			// 没有空闲 worker，任务完成时会再次唤醒
+			return next
+		}
+		q.pending.remove(id)
+		job, found := q.jobs.Get(id)
+		if !found {
+			// 已被移出队列（如过期或直接删除）
+			<-q.slots
+			continue
+		}
+		// 取走任务：持久化执行次数与可见性超时
+		job.Attempts++
+		job.RunAt = now.Add(q.cfg.visibility)
+		q.jobs.Set(job.ID, job)
+		q.inflight[job.ID] = struct{}{}
+		q.running.Add(1)
+		go q.run(job)
+	}
+}
+
+func (q *Queue[T]) run(job Job[T]) {
+	defer q.running.Done()
+	defer func() {
+		<-q.slots
+		q.notify()
+	}()
+
+	ctx, cancel := context.WithTimeout(q.ctx, q.cfg.visibility)
+	err := q.call(ctx, job)
+	cancel()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.inflight, job.ID)
+	now := time.Now()
+	switch {
+	case err == nil:
+		q.jobs.Delete(job.ID)
+	case q.ctx.Err() != nil:
+		// Close 中断：不计入执行次数，下次启动立即重新执行
+		job.Attempts--
+		job.RunAt = now
+		q.jobs.Set(job.ID, job)
+		q.pending.set(job.ID, job.RunAt)
+	case job.Attempts >= q.cfg.maxAttempts:
+		job.LastError = err.Error()
+		job.RunAt = now
+		q.move(q.jobs, q.dead, job)
+	default:
+		job.LastError = err.Error()
+		job.RunAt = now.Add(q.cfg.backoff(job.Attempts))
+		q.jobs.Set(job.ID, job)
+		q.pending.set(job.ID, job.RunAt)
+	}
+}
+
+// call 执行 Handler，panic 视为失败
+func (q *Queue[T]) call(ctx context.Context, job Job[T]) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("queue: handler panic: %v", r)
+		}
+	}()
+	return q.handler(ctx, job)
+}
+
+// move 在一个批次内把任务从 from 移到 to（调用方持有 q.mu）
+func (q *Queue[T]) move(from, to *kv.Bucket[Job[T]], job Job[T]) {
+	q.store.Batch(func(tx *kv.Tx[Job[T]]) error {
+		tx.Delete(kv.BucketKey(from.Name(), job.ID))
+		tx.Set(kv.BucketKey(to.Name(), job.ID), job)
+		return nil
+	})
+}
+
+// ---------------------------
+// 到期时间堆
+// ---------------------------
+
+type scheduled struct {
+	id    string
+	runAt time.Time
+	pos   int
+}
+
+// schedule 按 (runAt, id) 排序的最小堆，index 支持按 ID 更新与删除（调用方持有 q.mu）
+type schedule struct {
+	items []*scheduled
+	index map[string]*scheduled
+}
+
+func (s *schedule) Len() int { return len(s.items) }
+
+func (s *schedule) Less(i, j int) bool {
+	a, b := s.items[i], s.items[j]
+	if c := a.runAt.Compare(b.runAt); c != 0 {
+		return c < 0
+	}
+	return a.id < b.id
+}
+
+func (s *schedule) Swap(i, j int) {
+	s.items[i], s.items[j] = s.items[j], s.items[i]
+	s.items[i].pos = i
+	s.items[j].pos = j
+}
+
+func (s *schedule) Push(x any) {
+	it := x.(*scheduled)
+	it.pos = len(s.items)
+	s.items = append(s.items, it)
+}
+
+func (s *schedule) Pop() any {
+	n := len(s.items)
+	it := s.items[n-1]
+	s.items[n-1] = nil
+	s.items = s.items[:n-1]
+	return it
+}
+
+// set 加入任务或更新其到期时间
+func (s *schedule) set(id string, runAt time.Time) {
+	if it, ok := s.index[id]; ok {
+		it.runAt = runAt
+		heap.Fix(s, it.pos)
+		return
+	}
+	it := &scheduled{id: id, runAt: runAt}
+	s.index[id] = it
+	heap.Push(s, it)
+}
+
+func (s *schedule) remove(id string) {
+	if it, ok := s.index[id]; ok {
+		heap.Remove(s, it.pos)
+		delete(s.index, id)
+	}
+}
+
+// peek 返回最早到期的任务
+func (s *schedule) peek() (string, time.Time, bool) {
+	if len(s.items) == 0 {
+		return "", time.Time{}, false
+	}
+	return s.items[0].id, s.items[0].runAt, true
+}
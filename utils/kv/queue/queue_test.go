@@ -0,0 +1,257 @@
+package queue_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+	"github.com/Yuelioi/gkit/utils/kv/queue"
+)
+
+type email struct {
+	To string
+}
+
+func newStore(t *testing.T, path string) *kv.KVStore[queue.Job[email]] {
+	t.Helper()
+	store, err := kv.NewKVStore[queue.Job[email]](path, kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestQueue_ProcessAndDelay(t *testing.T) {
+	store := newStore(t, "")
+	defer store.Close()
+
+	var mu sync.Mutex
+	var got []string
+	q := queue.New(store, func(ctx context.Context, job queue.Job[email]) error {
+		mu.Lock()
+		got = append(got, job.Payload.To)
+		mu.Unlock()
+		return nil
+	}, queue.WithWorkers(1), queue.WithPollInterval(10*time.Millisecond))
+	defer q.Close()
+
+	start := time.Now()
+	q.Enqueue(email{To: "later"}, queue.Delay(50*time.Millisecond))
+	q.Enqueue(email{To: "a"})
+	q.Enqueue(email{To: "b"})
+
+	waitFor(t, func() bool { return q.Len() == 0 })
+	if time.Since(start) < 50*time.Millisecond {
+		t.Fatal("expected delayed job to wait")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 3 || got[0] != "a" || got[1] != "b" || got[2] != "later" {
+		t.Fatalf("unexpected order %v", got)
+	}
+
+	if _, err := q.Enqueue(email{}, queue.ID("x"), queue.Delay(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue(email{}, queue.ID("x")); !errors.Is(err, queue.ErrDuplicate) {
+		t.Fatalf("expected ErrDuplicate, got %v", err)
+	}
+	if !q.Cancel("x") || q.Len() != 0 {
+		t.Fatal("expected pending job cancelled")
+	}
+}
+
+func TestQueue_Reschedule(t *testing.T) {
+	store := newStore(t, "")
+	defer store.Close()
+
+	var ran atomic.Int32
+	q := queue.New(store, func(ctx context.Context, job queue.Job[email]) error {
+		if job.Payload.To == "far" {
+			t.Errorf("delayed job %s ran early", job.ID)
+		}
+		ran.Add(1)
+		return nil
+	}, queue.WithPollInterval(time.Hour))
+	defer q.Close()
+
+	for i := 0; i < 100; i++ {
+		q.Enqueue(email{To: "far"}, queue.Delay(time.Hour))
+	}
+	// 同 ID 取消后重新入队，按新的到期时间分发
+	q.Enqueue(email{To: "far"}, queue.ID("x"), queue.Delay(time.Hour))
+	q.Cancel("x")
+	q.Enqueue(email{To: "now"}, queue.ID("x"))
+
+	waitFor(t, func() bool { return ran.Load() == 1 })
+	if q.Len() != 100 {
+		t.Fatalf("expected delayed jobs kept, got %d", q.Len())
+	}
+}
+
+func TestQueue_RetryAndDeadLetter(t *testing.T) {
+	store := newStore(t, "")
+	defer store.Close()
+
+	var calls atomic.Int32
+	q := queue.New(store, func(ctx context.Context, job queue.Job[email]) error {
+		calls.Add(1)
+		if job.Payload.To == "flaky" && job.Attempts == 2 {
+			return nil
+		}
+		if job.Payload.To == "panic" {
+			panic("boom")
+		}
+		return errors.New("smtp down")
+	}, queue.WithMaxAttempts(3), queue.WithBackoff(func(int) time.Duration { return time.Millisecond }),
+		queue.WithPollInterval(5*time.Millisecond), queue.WithWorkers(2))
+	defer q.Close()
+
+	q.Enqueue(email{To: "flaky"}, queue.ID("flaky"))
+	q.Enqueue(email{To: "broken"}, queue.ID("broken"))
+	q.Enqueue(email{To: "panic"}, queue.ID("panic"))
+
+	waitFor(t, func() bool { return q.Len() == 0 })
+	if n := calls.Load(); n != 2+3+3 {
+		t.Fatalf("expected 8 calls, got %d", n)
+	}
+	dead := q.DeadLetters()
+	if len(dead) != 2 || dead[0].ID != "broken" || dead[0].Attempts != 3 || dead[0].LastError != "smtp down" {
+		t.Fatalf("unexpected dead letters %+v", dead)
+	}
+	if dead[1].LastError == "" {
+		t.Fatal("expected panic recorded as error")
+	}
+
+	if err := q.Requeue("broken"); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Requeue("missing"); !errors.Is(err, queue.ErrNotDead) {
+		t.Fatalf("expected ErrNotDead, got %v", err)
+	}
+	waitFor(t, func() bool { return len(q.DeadLetters()) == 2 && q.Len() == 0 })
+	if n := calls.Load(); n != 8+3 {
+		t.Fatalf("expected requeued job to run 3 more times, got %d calls", n)
+	}
+}
+
+func TestQueue_VisibilityTimeout(t *testing.T) {
+	store := newStore(t, "")
+	defer store.Close()
+
+	var attempts atomic.Int32
+	q := queue.New(store, func(ctx context.Context, job queue.Job[email]) error {
+		if attempts.Add(1) == 1 {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}, queue.WithVisibilityTimeout(20*time.Millisecond), queue.WithBackoff(func(int) time.Duration { return 0 }),
+		queue.WithPollInterval(5*time.Millisecond))
+	defer q.Close()
+
+	q.Enqueue(email{To: "slow"})
+	waitFor(t, func() bool { return q.Len() == 0 })
+	if n := attempts.Load(); n != 2 {
+		t.Fatalf("expected retry after visibility timeout, got %d attempts", n)
+	}
+}
+
+func TestQueue_SurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+
+	// 生产者进程：只入队
+	store := newStore(t, path)
+	q := queue.New[email](store, nil)
+	q.Enqueue(email{To: "a"})
+	q.Enqueue(email{To: "b"}, queue.Delay(20*time.Millisecond))
+	q.Close()
+	store.Close()
+
+	store2 := newStore(t, path)
+	defer store2.Close()
+	done := make(chan string, 2)
+	q2 := queue.New(store2, func(ctx context.Context, job queue.Job[email]) error {
+		done <- job.Payload.To
+		return nil
+	}, queue.WithPollInterval(5*time.Millisecond))
+	defer q2.Close()
+
+	for _, want := range []string{"a", "b"} {
+		select {
+		case got := <-done:
+			if got != want {
+				t.Fatalf("expected %s, got %s", want, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("job not processed after restart")
+		}
+	}
+}
+
+func TestQueue_CloseDrains(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	store := newStore(t, path)
+
+	started := make(chan struct{})
+	var finished atomic.Bool
+	q := queue.New(store, func(ctx context.Context, job queue.Job[email]) error {
+		close(started)
+		time.Sleep(30 * time.Millisecond)
+		finished.Store(true)
+		return nil
+	})
+	q.Enqueue(email{To: "a"})
+	<-started
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !finished.Load() || q.Len() != 0 {
+		t.Fatal("expected Close to wait for the running job")
+	}
+	if _, err := q.Enqueue(email{}); !errors.Is(err, queue.ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	store.Close()
+}
+
+func TestQueue_DrainTimeoutReleasesJob(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	store := newStore(t, path)
+
+	started := make(chan struct{})
+	q := queue.New(store, func(ctx context.Context, job queue.Job[email]) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, queue.WithDrainTimeout(10*time.Millisecond), queue.WithVisibilityTimeout(time.Hour))
+	id, _ := q.Enqueue(email{To: "a"})
+	<-started
+	q.Close()
+	store.Close()
+
+	// 中断的任务不计入次数，重启后立即可执行
+	store2 := newStore(t, path)
+	defer store2.Close()
+	q2 := queue.New[email](store2, nil)
+	job, ok := q2.Get(id)
+	if !ok || job.Attempts != 0 || job.RunAt.After(time.Now()) {
+		t.Fatalf("expected released job, got %+v %v", job, ok)
+	}
+}
@@ -0,0 +1,233 @@
+package kv
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ---------------------------
+// 外部修改检测与热加载
+// ---------------------------
+//
+// 开启 WithHotReload 或 LockCooperative 后，KVStore 记录主文件最近一次读写时的文件信息与内容哈希：
+// 后台按间隔轮询（mtime / size 变化时再比较哈希），每次保存前也会检查，
+// 发现外部修改时按 ConflictPolicy 与内存合并，避免覆盖手工编辑或其它进程的写入。
+// 合并产生的变更以 EventSet / EventDelete 投递，随后投递一个 EventReload。
+
+// ConflictPolicy 外部修改与本地未保存修改冲突时的处理方式
+type ConflictPolicy int
+
+const (
+	// ConflictKeepLocal 本地自上次保存以来写过（含删除）的键保留本地版本，其余以文件为准（默认）
+	ConflictKeepLocal ConflictPolicy = iota
+	// ConflictKeepFile 以文件为准，丢弃本地未保存的修改
+	ConflictKeepFile
+)
+
+// WithHotReload 按 interval 轮询主文件，外部修改后自动重新加载（默认 0 不轮询，不支持 WAL 模式）
+func WithHotReload(interval time.Duration) Option {
+	return func(c *config) { c.reloadInterval = interval }
+}
+
+// WithConflictPolicy 设置热加载 / 协作锁合并时的冲突策略（默认 ConflictKeepLocal）
+func WithConflictPolicy(p ConflictPolicy) Option {
+	return func(c *config) { c.conflict = p }
+}
+
+// fileSync 主文件的同步状态
+type fileSync struct {
+	// 最近一次读写主文件时的文件信息与内容哈希（受 saveMu 保护）
+	stamp os.FileInfo
+	hash  [sha256.Size]byte
+}
+
+// changeSet 记录自上次保存以来写过（含删除）的键
+type changeSet struct {
+	mu      sync.Mutex
+	changed map[string]struct{}
+}
+
+func newChangeSet() *changeSet {
+	return &changeSet{changed: make(map[string]struct{})}
+}
+
+// mark 记录本地修改（调用方持有分片写锁）
+func (cs *changeSet) mark(key string) {
+	cs.mu.Lock()
+	cs.changed[key] = struct{}{}
+	cs.mu.Unlock()
+}
+
+// take 取出并清空修改记录
+func (cs *changeSet) take() map[string]struct{} {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	changed := cs.changed
+	cs.changed = make(map[string]struct{})
+	return changed
+}
+
+// peek 返回修改记录的副本
+func (cs *changeSet) peek() map[string]struct{} {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	changed := make(map[string]struct{}, len(cs.changed))
+	for k := range cs.changed {
+		changed[k] = struct{}{}
+	}
+	return changed
+}
+
+// restore 保存失败时放回修改记录
+func (cs *changeSet) restore(changed map[string]struct{}) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for k := range changed {
+		cs.changed[k] = struct{}{}
+	}
+}
+
+// check 返回被外部修改后的文件内容与读取前的文件信息；未修改（或文件不存在）时 raw 为 nil
+func (fs *fileSync) check(path string) (raw []byte, st os.FileInfo, err error) {
+	st, err = os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	if fs.stamp != nil && os.SameFile(st, fs.stamp) &&
+		st.ModTime().Equal(fs.stamp.ModTime()) && st.Size() == fs.stamp.Size() {
+		return nil, nil, nil
+	}
+	raw, err = os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 仅 mtime 变化而内容相同（如 touch）
+	if fs.stamp != nil && sha256.Sum256(raw) == fs.hash {
+		fs.stamp = st
+		return nil, nil, nil
+	}
+	return raw, st, nil
+}
+
+func (fs *fileSync) record(st os.FileInfo, data []byte) {
+	fs.stamp = st
+	fs.hash = sha256.Sum256(data)
+}
+
+// ---------------------------
+// 热加载
+// ---------------------------
+
+// Reload 立即检查主文件，被外部修改时按 ConflictPolicy 重新加载。
+// 未开启 WithHotReload / LockCooperative 时无操作。
+func (s *KVStore[V]) Reload() error {
+	if s.fsync == nil {
+		return nil
+	}
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	if s.shared() {
+		if err := s.flock.lockSave(); err != nil {
+			return err
+		}
+		defer s.flock.unlockSave()
+	}
+	return s.reloadFile()
+}
+
+func (s *KVStore[V]) reloadLoop(interval time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.reportError(s.Reload())
+		}
+	}
+}
+
+func (s *KVStore[V]) shared() bool {
+	return s.flock != nil && s.flock.mode == LockCooperative
+}
+
+// reloadFile 检查并合并外部修改（调用方需持有 saveMu，协作模式下还需持有文件锁）
+func (s *KVStore[V]) reloadFile() error {
+	raw, st, err := s.fsync.check(s.filePath)
+	if err != nil || raw == nil {
+		return err
+	}
+	external := map[string]item[V]{}
+	if len(raw) > 0 {
+		if external, _, err = s.decodeFile(raw, false); err != nil {
+			return fmt.Errorf("kv: reload %s: %w", s.filePath, err)
+		}
+	}
+
+	s.lockAll()
+	var keep map[string]struct{}
+	if s.conflict == ConflictKeepLocal {
+		keep = s.changes.peek()
+	} else {
+		s.changes.take()
+		s.dirty.Store(false)
+	}
+	events := []Event[V]{}
+	s.batchEvents = &events
+	s.mergeLocked(external, keep, time.Now().UnixNano())
+	s.batchEvents = nil
+	if s.watch.observed() {
+		events = append(events, Event[V]{Type: EventReload})
+		s.shards[0].pending = append(s.shards[0].pending, events...)
+	}
+	s.fsync.record(st, raw)
+	s.unlockAll("")
+	return nil
+}
+
+// mergeLocked 把外部文件内容合并进内存：keep 中的键保留本地版本，其余以外部为准。
+// 调用方需持有全部分片的锁。
+func (s *KVStore[V]) mergeLocked(external map[string]item[V], keep map[string]struct{}, now int64) {
+	for _, sh := range s.shards {
+		for k, old := range sh.data {
+			if _, mine := keep[k]; mine {
+				continue
+			}
+			if _, ok := external[k]; !ok {
+				delete(sh.data, k)
+				s.count.Add(-1)
+				s.emitLocked(sh, Event[V]{Type: EventDelete, Key: k, Old: old.Value, HasOld: true})
+			}
+		}
+	}
+	for k, it := range external {
+		if _, mine := keep[k]; mine {
+			continue
+		}
+		sh := s.shardFor(k)
+		old, existed := sh.data[k]
+		if existed && reflect.DeepEqual(old, it) {
+			continue
+		}
+		if !existed {
+			s.count.Add(1)
+		}
+		sh.data[k] = it
+		sh.trackExpiry(k, it.ExpireAt)
+		live := existed && !old.expired(now)
+		s.emitLocked(sh, Event[V]{Type: EventSet, Key: k, Old: old.Value, HasOld: live, New: it.Value})
+	}
+	if s.limit != nil {
+		s.resetLimiterLocked()
+	}
+	s.reindexLocked()
+}
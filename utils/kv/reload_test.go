@@ -0,0 +1,144 @@
+package kv_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	// 确保 mtime 与上次写入不同
+	time.Sleep(10 * time.Millisecond)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKVStore_HotReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hot.json")
+	store, err := kv.NewKVStore[string](path, kv.WithHotReload(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.Set("a", "1")
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	ch, cancel := store.Watch("")
+	defer cancel()
+	writeFile(t, path, `{"a":{"value":"fixed"}}`)
+
+	if ev := recv(t, ch); ev.Type != kv.EventSet || ev.Key != "a" || ev.New != "fixed" {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	if ev := recv(t, ch); ev.Type != kv.EventReload {
+		t.Fatalf("expected reload event, got %+v", ev)
+	}
+	if v, _ := store.Get("a"); v != "fixed" {
+		t.Fatalf("expected hand edit loaded, got %q", v)
+	}
+}
+
+func TestKVStore_ReloadConflictPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy kv.ConflictPolicy
+		want   string
+	}{
+		{kv.ConflictKeepLocal, "local"},
+		{kv.ConflictKeepFile, "file"},
+	} {
+		path := filepath.Join(t.TempDir(), "conflict.json")
+		store, err := kv.NewKVStore[string](path, kv.WithHotReload(time.Hour), kv.WithConflictPolicy(tc.policy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.Set("x", "saved")
+		store.Set("y", "saved")
+		store.Save()
+
+		store.Set("x", "local")
+		writeFile(t, path, `{"x":{"value":"file"},"y":{"value":"file"}}`)
+
+		// 保存前先合并，不会覆盖外部修改
+		if err := store.Save(); err != nil {
+			t.Fatal(err)
+		}
+		if v, _ := store.Get("x"); v != tc.want {
+			t.Fatalf("policy %d: expected x=%q, got %q", tc.policy, tc.want, v)
+		}
+		if v, _ := store.Get("y"); v != "file" {
+			t.Fatalf("policy %d: expected y from file, got %q", tc.policy, v)
+		}
+		store.Close()
+	}
+}
+
+func TestKVStore_ReloadIgnoresTouch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "touch.json")
+	store, _ := kv.NewKVStore[string](path, kv.WithHotReload(time.Hour))
+	defer store.Close()
+	store.Set("a", "1")
+	store.Save()
+
+	ch, cancel := store.Watch("")
+	defer cancel()
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-ch:
+		t.Fatalf("expected no reload for unchanged content, got %+v", ev)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestKVStore_ReloadWithEviction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "evict.json")
+	store, err := kv.NewKVStore[int](path, kv.WithHotReload(time.Hour), kv.WithMaxEntries(8), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	// 合并外部修改时重建容量状态，写入方仍在分片锁外执行淘汰
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				store.Set(fmt.Sprintf("w%d-%d", g, i), i)
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		writeFile(t, path, fmt.Sprintf(`{"ext%d":{"value":%d}}`, i, i))
+		if err := store.Reload(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if n := len(store.Keys()); n > 8 {
+		t.Fatalf("expected at most 8 keys, got %d", n)
+	}
+}
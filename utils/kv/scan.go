@@ -0,0 +1,80 @@
+package kv
+
+import (
+	"iter"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ---------------------------
+// 有序遍历 / 前缀扫描 / 分页
+// ---------------------------
+
+// ScanOption 控制 Scan / Range 的分页
+type ScanOption func(*scanOptions)
+
+type scanOptions struct {
+	limit int
+	after string
+}
+
+// Limit 最多返回 n 条（<= 0 不限制）
+func Limit(n int) ScanOption {
+	return func(o *scanOptions) { o.limit = n }
+}
+
+// After 从游标之后开始（不含游标本身）。游标即上一页最后一个 key。
+func After(cursor string) ScanOption {
+	return func(o *scanOptions) { o.after = cursor }
+}
+
+// Scan 按 key 升序遍历以 prefix 开头的未过期条目（不含 Bucket 内的键）。
+//
+//	for k, v := range store.Scan("user:123:", kv.After(cursor), kv.Limit(50)) { cursor = k }
+//
+// 匹配的条目在遍历开始时逐个分片复制，遍历过程中不持有锁，可以在循环体内读写 KVStore。
+func (s *KVStore[V]) Scan(prefix string, opts ...ScanOption) iter.Seq2[string, V] {
+	return s.scan(func(k string) bool { return strings.HasPrefix(k, prefix) && !isBucketKey(k) }, 0, opts)
+}
+
+// Range 按 key 升序遍历 [start, end) 区间内的未过期条目，end 为空表示不设上界
+func (s *KVStore[V]) Range(start, end string, opts ...ScanOption) iter.Seq2[string, V] {
+	return s.scan(func(k string) bool { return k >= start && (end == "" || k < end) && !isBucketKey(k) }, 0, opts)
+}
+
+type kvPair[V any] struct {
+	key   string
+	value V
+}
+
+// scan 按内部 key 过滤与排序，游标与返回的 key 都去掉前 trim 个字节（Bucket 前缀）
+func (s *KVStore[V]) scan(match func(string) bool, trim int, opts []ScanOption) iter.Seq2[string, V] {
+	var o scanOptions
+	for _, fn := range opts {
+		fn(&o)
+	}
+	return func(yield func(string, V) bool) {
+		now := time.Now().UnixNano()
+		var pairs []kvPair[V]
+		for _, sh := range s.shards {
+			sh.mu.RLock()
+			for k, it := range sh.data {
+				if it.expired(now) || !match(k) || (o.after != "" && k[trim:] <= o.after) {
+					continue
+				}
+				pairs = append(pairs, kvPair[V]{key: k, value: it.Value})
+			}
+			sh.mu.RUnlock()
+		}
+		slices.SortFunc(pairs, func(a, b kvPair[V]) int { return strings.Compare(a.key, b.key) })
+		if o.limit > 0 && len(pairs) > o.limit {
+			pairs = pairs[:o.limit]
+		}
+		for _, p := range pairs {
+			if !yield(p.key[trim:], p.value) {
+				return
+			}
+		}
+	}
+}
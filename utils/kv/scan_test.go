@@ -0,0 +1,114 @@
+package kv_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+func TestKVStore_ScanPaging(t *testing.T) {
+	store, err := kv.NewKVStore[int]("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	for i := 9; i >= 0; i-- {
+		store.Set(fmt.Sprintf("user:1:%02d", i), i)
+	}
+	store.Set("user:2:00", 100)
+	store.SetWithTTL("user:1:zz", -1, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	var got []int
+	cursor := ""
+	pages := 0
+	for {
+		n := 0
+		for k, v := range store.Scan("user:1:", kv.After(cursor), kv.Limit(4)) {
+			got = append(got, v)
+			cursor = k
+			n++
+		}
+		if n == 0 {
+			break
+		}
+		pages++
+	}
+	if pages != 3 {
+		t.Fatalf("expected 3 pages, got %d", pages)
+	}
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("expected sorted values without expired items, got %v", got)
+		}
+	}
+	if len(got) != 10 {
+		t.Fatalf("expected 10 values, got %v", got)
+	}
+}
+
+func TestKVStore_Range(t *testing.T) {
+	store, err := kv.NewKVStore[string]("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	for _, k := range []string{"a", "b", "c", "d"} {
+		store.Set(k, k)
+	}
+
+	var keys []string
+	for k := range store.Range("b", "d") {
+		keys = append(keys, k)
+	}
+	if fmt.Sprint(keys) != "[b c]" {
+		t.Fatalf("expected [b c], got %v", keys)
+	}
+
+	keys = nil
+	for k := range store.Range("c", "") {
+		keys = append(keys, k)
+		// 遍历过程中可以写入
+		store.Set("z"+k, k)
+	}
+	if fmt.Sprint(keys) != "[c d]" {
+		t.Fatalf("expected [c d], got %v", keys)
+	}
+}
+
+func TestKVStore_ScanBucket(t *testing.T) {
+	store, err := kv.NewKVStore[int]("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.Set("a", 1)
+	store.Bucket("b").Set("x1", 2)
+	store.Bucket("b").Set("x2", 3)
+	store.Bucket("c").Set("x3", 4)
+
+	// 按名称遍历，key 与游标都不含 Bucket 前缀
+	var keys []string
+	for k := range store.ScanBucket("b", "x", kv.After("x1")) {
+		keys = append(keys, k)
+	}
+	if len(keys) != 1 || keys[0] != "x2" {
+		t.Fatalf("unexpected keys %q", keys)
+	}
+	for k := range store.ScanBucket("missing", "") {
+		t.Fatalf("expected empty scan of unknown bucket, got %q", k)
+	}
+	// 根层面的 Scan 不含 Bucket 内的键，即使前缀是内部形式
+	for k := range store.Scan("") {
+		if k != "a" {
+			t.Fatalf("expected root scan to skip bucket keys, got %q", k)
+		}
+	}
+	for k := range store.Scan(kv.BucketKey("b", "")) {
+		t.Fatalf("expected no bucket keys from root scan, got %q", k)
+	}
+}
@@ -0,0 +1,117 @@
+package kv
+
+import (
+	"hash/maphash"
+	"sync"
+)
+
+// ---------------------------
+// 分片
+// ---------------------------
+//
+// 数据按 key 的哈希分布到多个分片，每个分片有独立的读写锁，
+// 单键操作只竞争所在分片的锁；Batch / View / 保存快照 按分片下标顺序锁住全部分片。
+
+// WithShards 设置分片数量（默认 16，1 即单锁单 map）
+func WithShards(n int) Option {
+	return func(c *config) { c.shards = n }
+}
+
+type shard[V any] struct {
+	mu   sync.RWMutex
+	data map[string]item[V]
+	// 当前临界区内待投递的事件（受 mu 保护）
+	pending []Event[V]
+	// 带过期时间的键，按过期时间排序（受 mu 保护）
+	expiry expiryHeap
+}
+
+func newShards[V any](n int) []*shard[V] {
+	if n < 1 {
+		n = 1
+	}
+	shards := make([]*shard[V], n)
+	for i := range shards {
+		shards[i] = &shard[V]{data: make(map[string]item[V])}
+	}
+	return shards
+}
+
+func (s *KVStore[V]) shardFor(key string) *shard[V] {
+	if len(s.shards) == 1 {
+		return s.shards[0]
+	}
+	return s.shards[maphash.String(s.seed, key)%uint64(len(s.shards))]
+}
+
+// lock 锁住 key 所在分片并返回
+func (s *KVStore[V]) lock(key string) *shard[V] {
+	sh := s.shardFor(key)
+	sh.mu.Lock()
+	return sh
+}
+
+func (s *KVStore[V]) lockAll() {
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+	}
+}
+
+// unlockAll 释放全部分片后再统一投递事件（避免订阅者阻塞时仍持有其它分片的锁），keep 同 unlock
+func (s *KVStore[V]) unlockAll(keep string) {
+	var events []Event[V]
+	for _, sh := range s.shards {
+		events = append(events, sh.pending...)
+		sh.pending = nil
+	}
+	var t uint64
+	if len(events) > 0 {
+		t = s.watch.ticket()
+	}
+	for _, sh := range s.shards {
+		sh.mu.Unlock()
+	}
+	if len(events) > 0 {
+		s.watch.dispatch(t, events)
+	}
+	s.enforceLimit(keep)
+}
+
+func (s *KVStore[V]) rlockAll() {
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+	}
+}
+
+func (s *KVStore[V]) runlockAll() {
+	for _, sh := range s.shards {
+		sh.mu.RUnlock()
+	}
+}
+
+// snapshotLocked 合并所有分片为一个 map（调用方需持有全部分片的锁）。
+// 只复制条目，不做编码，编码在锁外进行。
+func (s *KVStore[V]) snapshotLocked() map[string]item[V] {
+	snap := make(map[string]item[V], s.count.Load())
+	for _, sh := range s.shards {
+		for k, it := range sh.data {
+			snap[k] = it
+		}
+	}
+	return snap
+}
+
+// replaceLocked 用 data 替换全部内容（调用方需持有全部分片的锁）
+func (s *KVStore[V]) replaceLocked(data map[string]item[V]) {
+	for _, sh := range s.shards {
+		sh.data = make(map[string]item[V])
+	}
+	for k, it := range data {
+		s.shardFor(k).data[k] = it
+	}
+	for _, sh := range s.shards {
+		sh.rebuildExpiry()
+	}
+	s.count.Store(int64(len(data)))
+	s.reindexLocked()
+}
@@ -0,0 +1,90 @@
+package kv_test
+
+import (
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+// shards = 1 等价于原先的单锁单 map 设计
+
+func benchShardCounts(b *testing.B, fn func(b *testing.B, shards int)) {
+	for _, n := range []int{1, 16, 64} {
+		b.Run("shards="+strconv.Itoa(n), func(b *testing.B) { fn(b, n) })
+	}
+}
+
+func BenchmarkKVStore_ParallelGet(b *testing.B) {
+	benchShardCounts(b, func(b *testing.B, shards int) {
+		store, _ := kv.NewKVStore[int]("", kv.WithShards(shards), kv.WithSaveInterval(0))
+		defer store.Close()
+		for i := 0; i < 10000; i++ {
+			store.Set(strconv.Itoa(i), i)
+		}
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				store.Get(strconv.Itoa(i % 10000))
+				i++
+			}
+		})
+	})
+}
+
+func BenchmarkKVStore_ParallelMixed(b *testing.B) {
+	benchShardCounts(b, func(b *testing.B, shards int) {
+		store, _ := kv.NewKVStore[int]("", kv.WithShards(shards), kv.WithSaveInterval(0))
+		defer store.Close()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				key := strconv.Itoa(i % 10000)
+				if i%4 == 0 {
+					store.Set(key, i)
+				} else {
+					store.Get(key)
+				}
+				i++
+			}
+		})
+	})
+}
+
+// 持续保存大 store 时的读延迟：编码在锁外进行，读方只在快照复制期间等待
+func BenchmarkKVStore_GetDuringSave(b *testing.B) {
+	benchShardCounts(b, func(b *testing.B, shards int) {
+		path := filepath.Join(b.TempDir(), "bench.json")
+		store, _ := kv.NewKVStore[string](path, kv.WithShards(shards), kv.WithSaveInterval(0))
+		defer store.Close()
+		for i := 0; i < 100000; i++ {
+			store.Set(strconv.Itoa(i), "value-"+strconv.Itoa(i))
+		}
+
+		var stop atomic.Bool
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; !stop.Load(); i++ {
+				store.Set("dirty", strconv.Itoa(i))
+				_ = store.Save()
+			}
+		}()
+
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				store.Get(strconv.Itoa(i % 100000))
+				i++
+			}
+		})
+		b.StopTimer()
+		stop.Store(true)
+		<-done
+	})
+}
@@ -0,0 +1,136 @@
+package kv
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ---------------------------
+// 统计与监控
+// ---------------------------
+
+// Stats 某一时刻的运行统计，计数器自 NewKVStore 起累计
+type Stats struct {
+	Hits        uint64 // Get 命中
+	Misses      uint64 // Get 未命中（含已过期）
+	Sets        uint64 // 写入次数（含覆盖）
+	Deletes     uint64 // 主动删除的条目数
+	Expirations uint64 // 过期删除的条目数
+	Evictions   uint64 // 因容量限制淘汰的条目数
+
+	Keys int64 // 未过期的键数量（含 Bucket 内的键）
+
+	LastSave         time.Time     // 最近一次成功保存的时间
+	LastSaveDuration time.Duration // 最近一次保存的编码与写盘耗时
+	SaveErrors       uint64        // 保存失败次数（含后台保存）
+
+	FileSize int64 // 主文件大小（memory-only 或文件不存在时为 0）
+	WALSize  int64 // 当前 WAL 大小（未启用时为 0）
+
+	WatchDropped uint64 // WatchDrop 策略下丢弃的事件数
+}
+
+// WithMetrics 每隔 interval 以当前 Stats 调用 fn，用于导出到监控系统（Close 时再调用一次）
+func WithMetrics(interval time.Duration, fn func(Stats)) Option {
+	return func(c *config) {
+		c.metricsInterval = interval
+		c.metrics = fn
+	}
+}
+
+type counters struct {
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	sets        atomic.Uint64
+	deletes     atomic.Uint64
+	expirations atomic.Uint64
+	saveErrors  atomic.Uint64
+
+	mu           sync.Mutex
+	lastSave     time.Time
+	lastDuration time.Duration
+}
+
+func (c *counters) saved(at time.Time, d time.Duration) {
+	c.mu.Lock()
+	c.lastSave = at
+	c.lastDuration = d
+	c.mu.Unlock()
+}
+
+// Stats 返回当前统计快照；Keys 需要遍历全部分片
+func (s *KVStore[V]) Stats() Stats {
+	st := Stats{
+		Hits:         s.stats.hits.Load(),
+		Misses:       s.stats.misses.Load(),
+		Sets:         s.stats.sets.Load(),
+		Deletes:      s.stats.deletes.Load(),
+		Expirations:  s.stats.expirations.Load(),
+		Evictions:    s.Evictions(),
+		SaveErrors:   s.stats.saveErrors.Load(),
+		WatchDropped: s.WatchDropped(),
+	}
+	s.stats.mu.Lock()
+	st.LastSave = s.stats.lastSave
+	st.LastSaveDuration = s.stats.lastDuration
+	s.stats.mu.Unlock()
+
+	now := time.Now().UnixNano()
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for _, it := range sh.data {
+			if !it.expired(now) {
+				st.Keys++
+			}
+		}
+		sh.mu.RUnlock()
+	}
+
+	if s.filePath != "" {
+		if fi, err := os.Stat(s.filePath); err == nil {
+			st.FileSize = fi.Size()
+		}
+	}
+	if s.wal != nil {
+		s.wal.mu.Lock()
+		st.WALSize = s.wal.size
+		s.wal.mu.Unlock()
+	}
+	return st
+}
+
+// OnSaveError 注册后台保存（及热加载）失败时的回调，再次调用会替换，传 nil 取消。
+// 显式调用 Save / Close 的错误仍直接返回，不经过回调。
+func (s *KVStore[V]) OnSaveError(fn func(err error)) {
+	if fn == nil {
+		s.onSaveError.Store(nil)
+		return
+	}
+	s.onSaveError.Store(&fn)
+}
+
+// reportError 报告后台任务中的错误
+func (s *KVStore[V]) reportError(err error) {
+	if err == nil {
+		return
+	}
+	if fn := s.onSaveError.Load(); fn != nil {
+		(*fn)(err)
+	}
+}
+
+func (s *KVStore[V]) metricsLoop(interval time.Duration, fn func(Stats)) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			fn(s.Stats())
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package kv_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+func TestKVStore_Stats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	store, err := kv.NewKVStore[int](path, kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	store.Set("a", 1)
+	store.Set("a", 2)
+	store.Set("b", 3)
+	store.SetWithTTL("t", 4, time.Millisecond)
+	store.Get("a")
+	store.Get("missing")
+	store.Delete("b")
+	time.Sleep(5 * time.Millisecond)
+	store.Get("t")
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	st := store.Stats()
+	if st.Hits != 1 || st.Misses != 2 {
+		t.Fatalf("unexpected hits/misses %d/%d", st.Hits, st.Misses)
+	}
+	if st.Sets != 4 || st.Deletes != 1 || st.Expirations != 1 {
+		t.Fatalf("unexpected sets/deletes/expirations %d/%d/%d", st.Sets, st.Deletes, st.Expirations)
+	}
+	if st.Keys != 1 {
+		t.Fatalf("expected 1 live key, got %d", st.Keys)
+	}
+	if st.LastSave.IsZero() || st.FileSize == 0 {
+		t.Fatalf("expected save recorded, got %+v", st)
+	}
+}
+
+func TestKVStore_MetricsAndSaveError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "broken.json")
+
+	exported := make(chan kv.Stats, 16)
+	store, err := kv.NewKVStore[int](path,
+		kv.WithSaveInterval(5*time.Millisecond),
+		kv.WithMetrics(5*time.Millisecond, func(st kv.Stats) {
+			select {
+			case exported <- st:
+			default:
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saveErr := make(chan error, 16)
+	store.OnSaveError(func(err error) {
+		select {
+		case saveErr <- err:
+		default:
+		}
+	})
+
+	// 让 .tmp 路径成为目录，使后台保存失败
+	if err := os.Mkdir(path+".tmp", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	store.Set("a", 1)
+
+	select {
+	case err := <-saveErr:
+		if err == nil {
+			t.Fatal("expected non-nil save error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected OnSaveError from background save")
+	}
+	select {
+	case <-exported:
+	case <-time.After(time.Second):
+		t.Fatal("expected metrics hook called")
+	}
+	if store.Stats().SaveErrors == 0 {
+		t.Fatal("expected save errors counted")
+	}
+
+	os.Remove(path + ".tmp")
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
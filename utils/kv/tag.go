@@ -0,0 +1,53 @@
+package kv
+
+import (
+	"slices"
+	"time"
+)
+
+// ---------------------------
+// 标签
+// ---------------------------
+//
+// 标签随条目一起持久化（快照、WAL、后端），用于按标签批量失效，
+// 例如把由同一用户派生的缓存都打上 "user:42"，权限变化时 InvalidateTag("user:42")。
+// 再次写入同一个键会以新写入的标签为准（Set / SetWithTTL 会清除标签）；Touch / Expire 保留标签。
+
+// SetWithTags 写入带标签的键，ttl 为零或负值表示不过期
+func (s *KVStore[V]) SetWithTags(key string, value V, ttl time.Duration, tags ...string) {
+	it := s.newItem(value, ttl)
+	if len(tags) > 0 {
+		it.Tags = slices.Compact(slices.Sorted(slices.Values(tags)))
+	}
+	sh := s.lock(key)
+	defer s.unlock(sh, key)
+	s.putLocked(sh, key, it)
+}
+
+// Tags 返回键的标签（按字典序），不存在或已过期时返回 nil
+func (s *KVStore[V]) Tags(key string) []string {
+	sh := s.shardFor(key)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	it, ok := liveLocked(sh, key, time.Now().UnixNano())
+	if !ok {
+		return nil
+	}
+	return slices.Clone(it.Tags)
+}
+
+// InvalidateTag 删除所有带 tag 的键并返回删除的数量。
+// 删除在一个批次内完成（同 Batch），订阅者收到 EventDelete。
+func (s *KVStore[V]) InvalidateTag(tag string) int {
+	n := 0
+	s.Batch(func(tx *Tx[V]) error {
+		for _, key := range s.tags.lookup(tag) {
+			if tx.Exists(key) {
+				n++
+			}
+			tx.Delete(key)
+		}
+		return nil
+	})
+	return n
+}
@@ -0,0 +1,102 @@
+package kv_test
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+func TestKVStore_InvalidateTag(t *testing.T) {
+	store, err := kv.NewKVStore[string]("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	store.SetWithTags("perm:1", "a", 0, "user:1", "perm")
+	store.SetWithTags("menu:1", "b", time.Hour, "user:1")
+	store.SetWithTags("perm:2", "c", 0, "user:2", "perm")
+	store.Set("plain", "d")
+
+	if tags := store.Tags("perm:1"); !slices.Equal(tags, []string{"perm", "user:1"}) {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+
+	ch, cancel := store.Watch("")
+	defer cancel()
+
+	if n := store.InvalidateTag("user:1"); n != 2 {
+		t.Fatalf("expected 2 keys invalidated, got %d", n)
+	}
+	for range 2 {
+		if ev := recv(t, ch); ev.Type != kv.EventDelete {
+			t.Fatalf("expected delete event, got %v", ev.Type)
+		}
+	}
+	if store.Exists("perm:1") || store.Exists("menu:1") {
+		t.Fatal("expected tagged keys removed")
+	}
+	if !store.Exists("perm:2") || !store.Exists("plain") {
+		t.Fatal("expected other keys kept")
+	}
+
+	// 覆盖写入以新标签为准
+	store.Set("perm:2", "e")
+	if n := store.InvalidateTag("perm"); n != 0 {
+		t.Fatalf("expected overwritten key to lose its tags, got %d", n)
+	}
+	if n := store.InvalidateTag("user:1"); n != 0 {
+		t.Fatalf("expected nothing left, got %d", n)
+	}
+}
+
+func TestKVStore_TagsPersisted(t *testing.T) {
+	for _, c := range []kv.Codec{kv.JSONCodec, kv.GobCodec, kv.BinaryCodec} {
+		t.Run(c.Name(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tags.db")
+			store, err := kv.NewKVStore[int](path, kv.WithCodec(c))
+			if err != nil {
+				t.Fatal(err)
+			}
+			store.SetWithTags("a", 1, time.Hour, "t1", "t2")
+			store.SetWithTags("b", 2, 0, "t2")
+			if err := store.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			store2, err := kv.NewKVStore[int](path, kv.WithCodec(c))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store2.Close()
+			if tags := store2.Tags("a"); !slices.Equal(tags, []string{"t1", "t2"}) {
+				t.Fatalf("expected tags restored, got %v", tags)
+			}
+			if n := store2.InvalidateTag("t2"); n != 2 {
+				t.Fatalf("expected tag index rebuilt after load, got %d", n)
+			}
+		})
+	}
+}
+
+func TestKVStore_TagsWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tags.json")
+	store, err := kv.NewKVStore[int](path, kv.WithWAL(true), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetWithTags("a", 1, 0, "t")
+
+	// 未保存快照，标签需从日志恢复
+	store2, err := kv.NewKVStore[int](path, kv.WithWAL(true), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store2.Close()
+	if n := store2.InvalidateTag("t"); n != 1 {
+		t.Fatalf("expected tag replayed from wal, got %d", n)
+	}
+}
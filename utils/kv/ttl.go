@@ -0,0 +1,157 @@
+package kv
+
+import "time"
+
+// ---------------------------
+// TTL 管理与滑动过期
+// ---------------------------
+//
+// Touch / Expire / Persist 只修改过期时间，不产生 Watch 事件，也不触发 OnEvict。
+
+// WithSlidingExpiration 开启滑动过期：带 ttl 写入的键在每次 Get 命中时把过期时间顺延 ttl（默认 false）。
+// 为减少写放大，顺延量不足 ttl 的 1/10 时不刷新。
+func WithSlidingExpiration(enabled bool) Option {
+	return func(c *config) { c.sliding = enabled }
+}
+
+// TTLState 键的过期状态
+type TTLState int
+
+const (
+	TTLNotFound TTLState = iota // 不存在或已过期
+	TTLNoExpiry                 // 存在且不过期
+	TTLExpiring                 // 存在且将在 Remaining 后过期
+)
+
+func (st TTLState) String() string {
+	switch st {
+	case TTLNotFound:
+		return "not_found"
+	case TTLNoExpiry:
+		return "no_expiry"
+	case TTLExpiring:
+		return "expiring"
+	}
+	return "unknown"
+}
+
+// TTLResult TTL 的查询结果，Remaining 仅在 State == TTLExpiring 时有意义
+type TTLResult struct {
+	State     TTLState
+	Remaining time.Duration
+}
+
+// Exists 键是否存在且未过期
+func (r TTLResult) Exists() bool { return r.State != TTLNotFound }
+
+// newItem 按 ttl 构造 item（ttl <= 0 表示不过期），开启滑动过期时记录窗口
+func (s *KVStore[V]) newItem(value V, ttl time.Duration) item[V] {
+	it := item[V]{Value: value, ExpireAt: expireAt(ttl)}
+	if s.sliding && ttl > 0 {
+		it.Slide = int64(ttl)
+	}
+	return it
+}
+
+// Touch 把已设置过期时间的键顺延为 ttl 后过期；不存在、已过期或不过期的键返回 false
+func (s *KVStore[V]) Touch(key string, ttl time.Duration) bool {
+	if ttl <= 0 {
+		return false
+	}
+	sh := s.lock(key)
+	defer s.unlock(sh, "")
+	it, ok := liveLocked(sh, key, time.Now().UnixNano())
+	if !ok || it.ExpireAt == 0 {
+		return false
+	}
+	s.retimeLocked(sh, key, it, ttl)
+	return true
+}
+
+// Expire 为键设置 ttl 后过期（不论原来是否过期）；ttl <= 0 时立即按过期删除。
+// 不存在或已过期的键返回 false。
+func (s *KVStore[V]) Expire(key string, ttl time.Duration) bool {
+	sh := s.lock(key)
+	defer s.unlock(sh, "")
+	it, ok := liveLocked(sh, key, time.Now().UnixNano())
+	if !ok {
+		return false
+	}
+	if ttl <= 0 {
+		s.removeLocked(sh, key, EventExpire)
+		return true
+	}
+	s.retimeLocked(sh, key, it, ttl)
+	return true
+}
+
+// Persist 移除键的过期时间；不存在、已过期或本就不过期的键返回 false
+func (s *KVStore[V]) Persist(key string) bool {
+	sh := s.lock(key)
+	defer s.unlock(sh, "")
+	it, ok := liveLocked(sh, key, time.Now().UnixNano())
+	if !ok || it.ExpireAt == 0 {
+		return false
+	}
+	it.ExpireAt, it.Slide = 0, 0
+	s.touchLocked(sh, key, it)
+	return true
+}
+
+// TTL 返回键的过期状态与剩余时间
+func (s *KVStore[V]) TTL(key string) TTLResult {
+	sh := s.shardFor(key)
+	sh.mu.RLock()
+	it, ok := sh.data[key]
+	sh.mu.RUnlock()
+	if !ok {
+		return TTLResult{State: TTLNotFound}
+	}
+	if it.ExpireAt == 0 {
+		return TTLResult{State: TTLNoExpiry}
+	}
+	now := time.Now().UnixNano()
+	if it.expired(now) {
+		return TTLResult{State: TTLNotFound}
+	}
+	return TTLResult{State: TTLExpiring, Remaining: time.Duration(it.ExpireAt - now)}
+}
+
+// retimeLocked 把 it 的过期时间改为 ttl 后（调用方需持有 sh 的写锁）
+func (s *KVStore[V]) retimeLocked(sh *shard[V], key string, it item[V], ttl time.Duration) {
+	it.ExpireAt = expireAt(ttl)
+	if it.Slide > 0 {
+		it.Slide = int64(ttl)
+	}
+	s.touchLocked(sh, key, it)
+}
+
+// touchLocked 写入只改变了过期时间的 item：持久化但不产生事件（调用方需持有 sh 的写锁）
+func (s *KVStore[V]) touchLocked(sh *shard[V], key string, it item[V]) {
+	sh.data[key] = it
+	sh.trackExpiry(key, it.ExpireAt)
+	s.dirty.Store(true)
+	if s.wal != nil {
+		s.appendWAL(walRecord[V]{Op: walOpSet, Key: key, Item: &it})
+	}
+	if s.changes != nil {
+		s.changes.mark(key)
+	}
+}
+
+// slide 命中滑动过期的键后顺延过期时间
+func (s *KVStore[V]) slide(key string) {
+	sh := s.lock(key)
+	defer s.unlock(sh, "")
+	now := time.Now().UnixNano()
+	it, ok := liveLocked(sh, key, now)
+	if !ok || it.Slide <= 0 || it.ExpireAt == 0 {
+		return
+	}
+	next := now + it.Slide
+	if next-it.ExpireAt < it.Slide/10 {
+		return
+	}
+	it.ExpireAt = next
+	s.touchLocked(sh, key, it)
+}
@@ -0,0 +1,86 @@
+package kv_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+func TestKVStore_TouchExpirePersist(t *testing.T) {
+	store, _ := kv.NewKVStore[int]("", kv.WithSaveInterval(0))
+	defer store.Close()
+
+	store.Set("plain", 1)
+	store.SetWithTTL("ttl", 2, time.Second)
+
+	if store.Touch("plain", time.Minute) {
+		t.Fatal("expected Touch to skip keys without ttl")
+	}
+	if !store.Touch("ttl", time.Hour) {
+		t.Fatal("expected Touch on ttl key")
+	}
+	if r := store.TTL("ttl"); r.State != kv.TTLExpiring || r.Remaining < 59*time.Minute {
+		t.Fatalf("expected ttl extended, got %+v", r)
+	}
+
+	if !store.Expire("plain", time.Minute) {
+		t.Fatal("expected Expire on plain key")
+	}
+	if r := store.TTL("plain"); r.State != kv.TTLExpiring {
+		t.Fatalf("expected plain expiring, got %+v", r)
+	}
+
+	if !store.Persist("ttl") || store.TTL("ttl").State != kv.TTLNoExpiry {
+		t.Fatal("expected Persist to remove ttl")
+	}
+	if store.Persist("ttl") {
+		t.Fatal("expected Persist on persistent key to return false")
+	}
+
+	if !store.Expire("plain", 0) || store.Exists("plain") {
+		t.Fatal("expected Expire(0) to remove key")
+	}
+	if r := store.TTL("missing"); r.State != kv.TTLNotFound || r.Exists() {
+		t.Fatalf("expected not found, got %+v", r)
+	}
+	if store.Touch("missing", time.Minute) || store.Expire("missing", time.Minute) {
+		t.Fatal("expected missing key untouched")
+	}
+}
+
+func TestKVStore_SlidingExpiration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sliding.json")
+	store, err := kv.NewKVStore[string](path, kv.WithSlidingExpiration(true), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetWithTTL("session", "s", 60*time.Millisecond)
+
+	// 持续访问，总时长超过 ttl 仍不过期
+	for i := 0; i < 6; i++ {
+		time.Sleep(20 * time.Millisecond)
+		if _, ok := store.Get("session"); !ok {
+			t.Fatalf("expected session kept alive on access %d", i)
+		}
+	}
+	time.Sleep(80 * time.Millisecond)
+	if _, ok := store.Get("session"); ok {
+		t.Fatal("expected session expired without access")
+	}
+
+	// 滑动窗口随文件持久化
+	store.SetWithTTL("persisted", "p", time.Hour)
+	store.Close()
+	store2, err := kv.NewKVStore[string](path, kv.WithSlidingExpiration(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store2.Close()
+	store2.Expire("persisted", time.Minute)
+	store2.Get("persisted")
+	if r := store2.TTL("persisted"); r.Remaining > time.Minute || r.Remaining < 59*time.Second {
+		t.Fatalf("expected Expire to update sliding window, got %+v", r)
+	}
+}
@@ -0,0 +1,154 @@
+package kv
+
+import (
+	"errors"
+	"time"
+)
+
+// ---------------------------
+// 事务：Batch / View
+// ---------------------------
+
+// ErrReadOnlyTx 在 View 事务中写入
+var ErrReadOnlyTx = errors.New("kv: write in read-only transaction")
+
+// Tx 事务句柄，仅在 Batch / View 的回调内有效。
+// 回调执行期间持有存储锁，不能再调用同一个 KVStore 的方法。
+type Tx[V any] struct {
+	s        *KVStore[V]
+	now      int64
+	readOnly bool
+
+	writes map[string]txWrite[V]
+	order  []string
+}
+
+type txWrite[V any] struct {
+	it     item[V]
+	delete bool
+}
+
+// Batch 在全部分片的写锁内执行 fn：fn 中的写入先暂存，fn 返回 nil 时全部生效，返回错误时全部丢弃。
+// WAL 模式下整个批次写为一条日志记录，崩溃后同样全有或全无。
+func (s *KVStore[V]) Batch(fn func(tx *Tx[V]) error) error {
+	s.lockAll()
+	defer s.unlockAll("")
+
+	tx := &Tx[V]{s: s, now: time.Now().UnixNano(), writes: make(map[string]txWrite[V])}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.order) == 0 {
+		return nil
+	}
+
+	if s.wal != nil {
+		s.walBatch = &[]walRecord[V]{}
+	}
+	events := []Event[V]{}
+	s.batchEvents = &events
+	for _, key := range tx.order {
+		w := tx.writes[key]
+		sh := s.shardFor(key)
+		if w.delete {
+			if _, ok := sh.data[key]; ok {
+				s.removeLocked(sh, key, EventDelete)
+			}
+		} else {
+			s.putLocked(sh, key, w.it)
+		}
+	}
+	s.batchEvents = nil
+	// 事件挂到第一个分片上，由 unlockAll 按应用顺序投递
+	s.shards[0].pending = append(s.shards[0].pending, events...)
+	if s.wal != nil {
+		ops := *s.walBatch
+		s.walBatch = nil
+		s.appendWAL(walRecord[V]{Op: walOpBatch, Ops: ops})
+	}
+	return nil
+}
+
+// View 在全部分片的读锁内执行 fn，期间看到的是一致的快照；fn 中的写入返回 ErrReadOnlyTx
+func (s *KVStore[V]) View(fn func(tx *Tx[V]) error) error {
+	s.rlockAll()
+	defer s.runlockAll()
+	return fn(&Tx[V]{s: s, now: time.Now().UnixNano(), readOnly: true})
+}
+
+// Get 读取 key，能看到本事务中已暂存的写入
+func (tx *Tx[V]) Get(key string) (V, bool) {
+	if w, ok := tx.writes[key]; ok {
+		if w.delete {
+			var zero V
+			return zero, false
+		}
+		return w.it.Value, true
+	}
+	it, ok := liveLocked(tx.s.shardFor(key), key, tx.now)
+	if ok && tx.s.limit != nil {
+		tx.s.limit.policy.access(key)
+	}
+	return it.Value, ok
+}
+
+// Exists 判断 key 是否存在且未过期
+func (tx *Tx[V]) Exists(key string) bool {
+	if w, ok := tx.writes[key]; ok {
+		return !w.delete
+	}
+	_, ok := liveLocked(tx.s.shardFor(key), key, tx.now)
+	return ok
+}
+
+// Keys 返回事务视角下所有未过期的键（顺序不保证）
+func (tx *Tx[V]) Keys() []string {
+	keys := make([]string, 0, tx.s.count.Load())
+	for _, sh := range tx.s.shards {
+		for k, it := range sh.data {
+			if _, staged := tx.writes[k]; staged || it.expired(tx.now) || isBucketKey(k) {
+				continue
+			}
+			keys = append(keys, k)
+		}
+	}
+	for k, w := range tx.writes {
+		if !w.delete {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// Set 暂存写入（不过期）
+func (tx *Tx[V]) Set(key string, value V) error {
+	return tx.stage(key, txWrite[V]{it: item[V]{Value: value}})
+}
+
+// SetWithTTL 暂存带 ttl 的写入（零或负值表示不过期）
+func (tx *Tx[V]) SetWithTTL(key string, value V, ttl time.Duration) error {
+	return tx.stage(key, txWrite[V]{it: tx.s.newItem(value, ttl)})
+}
+
+// Delete 暂存删除
+func (tx *Tx[V]) Delete(key string) error {
+	return tx.stage(key, txWrite[V]{delete: true})
+}
+
+func (tx *Tx[V]) stage(key string, w txWrite[V]) error {
+	if tx.readOnly {
+		return ErrReadOnlyTx
+	}
+	if _, ok := tx.writes[key]; ok {
+		// 同一键多次写入只保留最后一次，并按最后一次的顺序应用
+		for i, k := range tx.order {
+			if k == key {
+				tx.order = append(tx.order[:i], tx.order[i+1:]...)
+				break
+			}
+		}
+	}
+	tx.writes[key] = w
+	tx.order = append(tx.order, key)
+	return nil
+}
@@ -0,0 +1,94 @@
+package kv_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+func TestKVStore_BatchMove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tx.json")
+	store, err := kv.NewKVStore[[]string](path, kv.WithWAL(true), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set("todo", []string{"a", "b"})
+	store.Set("done", nil)
+
+	err = store.Batch(func(tx *kv.Tx[[]string]) error {
+		todo, _ := tx.Get("todo")
+		done, _ := tx.Get("done")
+		if err := tx.Set("todo", todo[1:]); err != nil {
+			return err
+		}
+		return tx.Set("done", append(done, todo[0]))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 通过 WAL 重放验证批次已持久化
+	store2, err := kv.NewKVStore[[]string](path, kv.WithWAL(true), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store2.Close()
+
+	err = store2.View(func(tx *kv.Tx[[]string]) error {
+		todo, _ := tx.Get("todo")
+		done, _ := tx.Get("done")
+		if len(todo) != 1 || todo[0] != "b" || len(done) != 1 || done[0] != "a" {
+			t.Fatalf("unexpected state todo=%v done=%v", todo, done)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKVStore_BatchRollback(t *testing.T) {
+	store, err := kv.NewKVStore[int]("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.Set("a", 1)
+
+	boom := errors.New("boom")
+	err = store.Batch(func(tx *kv.Tx[int]) error {
+		tx.Set("a", 2)
+		tx.Delete("a")
+		tx.Set("b", 3)
+		if tx.Exists("a") {
+			t.Fatal("expected staged delete visible inside tx")
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected boom, got %v", err)
+	}
+	if v, _ := store.Get("a"); v != 1 {
+		t.Fatalf("expected a unchanged, got %v", v)
+	}
+	if store.Exists("b") {
+		t.Fatal("expected b not written")
+	}
+}
+
+func TestKVStore_ViewReadOnly(t *testing.T) {
+	store, err := kv.NewKVStore[int]("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	err = store.View(func(tx *kv.Tx[int]) error {
+		return tx.Set("a", 1)
+	})
+	if !errors.Is(err, kv.ErrReadOnlyTx) {
+		t.Fatalf("expected ErrReadOnlyTx, got %v", err)
+	}
+}
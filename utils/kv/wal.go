@@ -0,0 +1,344 @@
+package kv
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+// ---------------------------
+// WAL（追加写日志）
+// ---------------------------
+//
+// 文件布局：
+//   <filePath>        快照（与非 WAL 模式格式一致）
+//   <filePath>.wal    当前日志
+//   <filePath>.wal.1  压缩过程中被轮转出的日志，快照落盘后删除
+//
+// 每条记录为一个帧：[4 字节长度][4 字节 CRC32][payload]，
+// 崩溃导致的残缺尾帧在重放时被截断丢弃。
+// 长度最高位为 1 的帧是元数据帧，内容为 Codec 文件头，每个日志文件以它开始，
+// 之后的记录按该 Codec 解码。
+
+const (
+	walFrameHeader = 8
+	walMetaFlag    = 1 << 31
+)
+
+type walOp string
+
+const (
+	walOpSet    walOp = "set"
+	walOpDelete walOp = "del"
+	walOpBatch  walOp = "batch"
+)
+
+// walRecord 单条日志记录；Set 携带完整 item（含绝对过期时间），重放是幂等的。
+// Batch 记录把一个事务内的多条记录放在同一帧中，保证全有或全无。
+type walRecord[V any] struct {
+	Op   walOp          `json:"op"`
+	Key  string         `json:"key,omitempty"`
+	Item *item[V]       `json:"item,omitempty"`
+	Ops  []walRecord[V] `json:"ops,omitempty"`
+}
+
+type walLog struct {
+	mu        sync.Mutex
+	path      string
+	header    []byte // Codec 文件头，写入每个新日志文件的开头
+	f         *os.File
+	size      int64
+	sync      bool
+	threshold int64
+	err       error // 最近一次追加失败的错误，下次快照成功后清除
+}
+
+// openWAL 打开（或创建）日志文件；reset 为 true 时丢弃已有日志
+func openWAL(filePath string, header []byte, sync bool, threshold int64, reset bool) (*walLog, error) {
+	w := &walLog{path: filePath + ".wal", header: header, sync: sync, threshold: threshold}
+	if reset {
+		if err := removeIfExists(w.rotatedPath()); err != nil {
+			return nil, err
+		}
+	}
+	flag := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if reset {
+		flag |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(w.path, flag, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	w.f = f
+	w.size = st.Size()
+	return w, nil
+}
+
+func (w *walLog) rotatedPath() string { return w.path + ".1" }
+
+func appendFrame(buf []byte, payload []byte, meta bool) []byte {
+	size := uint32(len(payload))
+	if meta {
+		size |= walMetaFlag
+	}
+	buf = binary.BigEndian.AppendUint32(buf, size)
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(payload))
+	return append(buf, payload...)
+}
+
+// write 追加一帧；失败时回退到写入前的长度，避免在日志中间留下残帧
+func (w *walLog) write(payload []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var buf []byte
+	if w.size == 0 {
+		buf = appendFrame(buf, w.header, true)
+	}
+	buf = appendFrame(buf, payload, false)
+
+	if _, err := w.f.Write(buf); err != nil {
+		_ = w.f.Truncate(w.size)
+		w.err = err
+		return err
+	}
+	if w.sync {
+		if err := w.f.Sync(); err != nil {
+			w.err = err
+			return err
+		}
+	}
+	w.size += int64(len(buf))
+	return nil
+}
+
+// needCompact 日志超过阈值或存在追加失败时需要压缩
+func (w *walLog) needCompact() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err != nil || (w.threshold > 0 && w.size >= w.threshold)
+}
+
+// rotate 将当前日志移到 .wal.1 并开启新日志。
+// 若上次压缩失败遗留了 .wal.1，则把当前日志追加到其末尾，保证不丢记录。
+func (w *walLog) rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	rotated := w.rotatedPath()
+	if _, err := os.Stat(rotated); err == nil {
+		if err := appendFile(rotated, w.path); err != nil {
+			return w.reopen(false, err)
+		}
+	} else if err := os.Rename(w.path, rotated); err != nil {
+		return w.reopen(false, err)
+	}
+	return w.reopen(true, nil)
+}
+
+// reopen 重新打开当前日志；cause 非空时原样返回（rotate 失败的回退路径）
+func (w *walLog) reopen(truncate bool, cause error) error {
+	flag := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if truncate {
+		flag |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(w.path, flag, 0o644)
+	if err != nil {
+		return errors.Join(cause, err)
+	}
+	w.f = f
+	if truncate {
+		w.size = 0
+	}
+	return cause
+}
+
+// dropRotated 快照落盘后删除轮转出的旧日志，并清除追加错误
+func (w *walLog) dropRotated() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := removeIfExists(w.rotatedPath()); err != nil {
+		return err
+	}
+	w.err = nil
+	return nil
+}
+
+func (w *walLog) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
+
+// appendWAL 编码并追加一条记录（调用方持有写锁，以保证记录顺序与内存一致）
+func (s *KVStore[V]) appendWAL(rec walRecord[V]) {
+	// Batch 进行中：先收集，由 Batch 统一写为一条记录
+	if s.walBatch != nil {
+		*s.walBatch = append(*s.walBatch, rec)
+		return
+	}
+	payload, err := s.codec.Marshal(rec)
+	if err == nil && s.keys != nil {
+		payload, _, err = seal(s.keys, payload)
+	}
+	if err != nil {
+		s.wal.mu.Lock()
+		s.wal.err = err
+		s.wal.mu.Unlock()
+		return
+	}
+	_ = s.wal.write(payload)
+}
+
+// replayWAL 依次重放 .wal.1 与 .wal，返回应用的记录数；
+// foreign 表示当前日志由其它 Codec 写出（或配置了加密但日志含明文记录），继续追加前需要先压缩。
+func replayWAL[V any](w *walLog, want Codec, keys KeyProvider, apply func(walRecord[V])) (total int, foreign bool, err error) {
+	for _, p := range []string{w.rotatedPath(), w.path} {
+		n, last, plain, err := replayFile(p, keys, apply)
+		if err != nil {
+			return total, false, err
+		}
+		total += n
+		if p == w.path && last != nil {
+			foreign = last.Name() != want.Name()
+		}
+		if keys != nil && plain {
+			foreign = true
+		}
+	}
+	// 截断后需要同步当前日志的大小
+	if st, err := os.Stat(w.path); err == nil {
+		w.size = st.Size()
+	}
+	return total, foreign, nil
+}
+
+// replayFile 读取单个日志文件，遇到残缺帧时截断文件并停止。
+// 返回最后生效的 Codec（空文件为 nil）与是否含未加密的记录。加密记录无法解密时返回 *DecryptError。
+func replayFile[V any](path string, keys KeyProvider, apply func(walRecord[V])) (int, Codec, bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil, false, nil
+		}
+		return 0, nil, false, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return 0, nil, false, err
+	}
+
+	// 出错时保留文件原样，交由调用方处理
+	valid, n, codec, plain, err := readFrames(bufio.NewReader(f), st.Size(), keys, apply)
+	if errors.Is(err, ErrUnknownCodec) {
+		return n, codec, plain, fmt.Errorf("%w in %s", err, path)
+	}
+	if err != nil {
+		return n, codec, plain, fmt.Errorf("%w (%s)", err, path)
+	}
+	if st.Size() > valid {
+		if err := f.Truncate(valid); err != nil {
+			return n, codec, plain, err
+		}
+	}
+	return n, codec, plain, nil
+}
+
+// readFrames 依次解码 r（共 total 字节）中的帧并应用，返回有效帧的总字节数（之后为残缺或损坏的内容）。
+// 读取不完整、长度越界、长度为 0 的记录帧（崩溃后常见的全零尾部，其校验和恰好为 0）
+// 以及校验和不符的帧视为残缺的尾部；校验通过却无法解码的记录说明配置与日志不一致，
+// 此时返回错误而不是把它连同后续记录一起丢弃。
+func readFrames[R any](r io.Reader, total int64, keys KeyProvider, apply func(R)) (valid int64, n int, codec Codec, plain bool, err error) {
+	var hdr [walFrameHeader]byte
+	for {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			break
+		}
+		size := binary.BigEndian.Uint32(hdr[0:4])
+		meta := size&walMetaFlag != 0
+		size &^= walMetaFlag
+		sum := binary.BigEndian.Uint32(hdr[4:8])
+		if (!meta && size == 0) || int64(size) > total-valid-int64(walFrameHeader) {
+			break
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(payload) != sum {
+			break
+		}
+		if meta {
+			name, _, ok := splitHeader(payload)
+			if !ok {
+				return valid, n, codec, plain, fmt.Errorf("kv: wal meta frame at offset %d is malformed", valid)
+			}
+			c, found := lookupCodec(name)
+			if !found {
+				return valid, n, nil, plain, fmt.Errorf("%w: %q", ErrUnknownCodec, name)
+			}
+			codec = c
+		} else {
+			// 没有元数据帧的旧日志按 JSON 解码
+			if codec == nil {
+				codec = JSONCodec
+			}
+			opened, keyID, err := unseal(keys, payload)
+			if err != nil {
+				return valid, n, codec, plain, err
+			}
+			plain = plain || keyID == ""
+			payload = opened
+			var rec R
+			if err := codec.Unmarshal(payload, &rec); err != nil {
+				return valid, n, codec, plain, fmt.Errorf("kv: wal record %d: %w", n+1, err)
+			}
+			apply(rec)
+			n++
+		}
+		valid += int64(walFrameHeader) + int64(size)
+	}
+	return valid, n, codec, plain, nil
+}
+
+// appendFile 将 src 的内容追加到 dst
+func appendFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func removeIfExists(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package kv_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+func TestKVStore_WALSurvivesCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.json")
+
+	store, err := kv.NewKVStore[int](path, kv.WithWAL(true), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set("a", 1)
+	store.SetWithTTL("b", 2, time.Hour)
+	store.Set("c", 3)
+	store.Delete("c")
+
+	// 未 Close、未保存快照：模拟进程在两次后台 tick 之间崩溃
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected no snapshot before compaction, got %v", err)
+	}
+
+	store2, err := kv.NewKVStore[int](path, kv.WithWAL(true), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store2.Close()
+
+	if v, ok := store2.Get("a"); !ok || v != 1 {
+		t.Fatalf("expected a=1 after replay, got %v %v", v, ok)
+	}
+	if ttl := store2.TTL("b"); ttl.State != kv.TTLExpiring || ttl.Remaining <= 0 {
+		t.Fatalf("expected b to keep its ttl, got %+v", ttl)
+	}
+	if store2.Exists("c") {
+		t.Fatal("expected c deleted after replay")
+	}
+}
+
+func TestKVStore_WALCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.json")
+
+	store, err := kv.NewKVStore[string](path, kv.WithWAL(true), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set("k", "v1")
+	store.Set("k", "v2")
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := os.Stat(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Size() != 0 {
+		t.Fatalf("expected empty wal after compaction, got %d bytes", st.Size())
+	}
+
+	store.Set("k2", "v3")
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store2, err := kv.NewKVStore[string](path, kv.WithWAL(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store2.Close()
+
+	if v, _ := store2.Get("k"); v != "v2" {
+		t.Fatalf("expected v2, got %v", v)
+	}
+	if v, _ := store2.Get("k2"); v != "v3" {
+		t.Fatalf("expected v3, got %v", v)
+	}
+}
+
+func TestKVStore_WALTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.json")
+
+	store, err := kv.NewKVStore[int](path, kv.WithWAL(true), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set("a", 1)
+
+	// 追加一段残缺帧
+	f, err := os.OpenFile(path+".wal", os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 0, 99, 1, 2})
+	f.Close()
+
+	store2, err := kv.NewKVStore[int](path, kv.WithWAL(true), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store2.Set("b", 2)
+
+	store3, err := kv.NewKVStore[int](path, kv.WithWAL(true), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store3.Close()
+
+	if v, ok := store3.Get("a"); !ok || v != 1 {
+		t.Fatalf("expected a=1, got %v %v", v, ok)
+	}
+	if v, ok := store3.Get("b"); !ok || v != 2 {
+		t.Fatalf("expected b=2 written after torn tail, got %v %v", v, ok)
+	}
+}
+
+func TestKVStore_WALDecodeErrorKeepsLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.json")
+
+	store, err := kv.NewKVStore[int](path, kv.WithWAL(true), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set("a", 1)
+	store.Set("b", 2)
+
+	before, err := os.Stat(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 值类型不符：校验和正确但无法解码，不能当作残缺尾部截断
+	if _, err := kv.NewKVStore[string](path, kv.WithWAL(true), kv.WithSaveInterval(0)); err == nil {
+		t.Fatal("expected decode error")
+	}
+	after, err := os.Stat(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() != before.Size() {
+		t.Fatalf("expected wal left alone, size %d -> %d", before.Size(), after.Size())
+	}
+}
+
+func TestKVStore_WALZeroFilledTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.json")
+
+	store, err := kv.NewKVStore[int](path, kv.WithWAL(true), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set("a", 1)
+	before, err := os.Stat(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 断电后常见的全零尾部：空载荷的校验和恰好为 0
+	f, err := os.OpenFile(path+".wal", os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(make([]byte, 16))
+	f.Close()
+
+	store2, err := kv.NewKVStore[int](path, kv.WithWAL(true), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store2.Close()
+	if v, ok := store2.Get("a"); !ok || v != 1 {
+		t.Fatalf("expected a=1 before the zero tail, got %v %v", v, ok)
+	}
+	after, err := os.Stat(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() != before.Size() {
+		t.Fatalf("expected zero tail truncated, size %d -> %d", before.Size(), after.Size())
+	}
+}
+
+func TestKVStore_WALOversizedFrame(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.json")
+
+	store, err := kv.NewKVStore[int](path, kv.WithWAL(true), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set("a", 1)
+
+	// 长度字段越界的帧不分配内存，按残缺尾部处理
+	f, err := os.OpenFile(path+".wal", os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0x7f, 0xff, 0xff, 0xff, 0, 0, 0, 0})
+	f.Close()
+
+	store2, err := kv.NewKVStore[int](path, kv.WithWAL(true), kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store2.Close()
+	if v, ok := store2.Get("a"); !ok || v != 1 {
+		t.Fatalf("expected a=1, got %v %v", v, ok)
+	}
+}
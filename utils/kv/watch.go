@@ -0,0 +1,281 @@
+package kv
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ---------------------------
+// Watch 变更通知
+// ---------------------------
+
+// EventType 变更类型
+type EventType int
+
+const (
+	EventSet    EventType = iota // 新增或覆盖
+	EventDelete                  // 主动删除
+	EventExpire                  // 过期被清理（含 Get 惰性删除、覆盖已过期的键）
+	EventEvict                   // 因容量限制被淘汰
+	EventReload                  // 主文件被外部修改后重新加载（Key 为空，投递给所有订阅者）
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventDelete:
+		return "delete"
+	case EventExpire:
+		return "expire"
+	case EventEvict:
+		return "evict"
+	case EventReload:
+		return "reload"
+	}
+	return "unknown"
+}
+
+// Event 单次变更。HasOld 为 false 时 Old 为零值（新键）；删除类事件的 New 为零值。
+type Event[V any] struct {
+	Type   EventType
+	Key    string
+	Old    V
+	HasOld bool
+	New    V
+}
+
+// WatchPolicy 订阅者缓冲区满时的处理方式
+type WatchPolicy int
+
+const (
+	// WatchDrop 丢弃新事件（默认），写入方不受慢订阅者影响，丢弃数见 WatchDropped
+	WatchDrop WatchPolicy = iota
+	// WatchBlock 阻塞直到订阅者取走事件。
+	// 阻塞发生在释放存储锁之后，但事件按写入顺序投递：订阅者不能在消费 channel 的
+	// goroutine 中写入同一个 KVStore（包括可能惰性删除过期键的 Get），也不能等待
+	// 其他 goroutine 完成这类写入，否则缓冲区满时写入会排在正等待该订阅者的投递之后而死锁。
+	// 需要在订阅者中回写时请使用 WatchDrop。长时间不消费同样会拖慢所有写入。
+	WatchBlock
+)
+
+// WithWatchBuffer 设置每个订阅 channel 的缓冲大小（默认 64）
+func WithWatchBuffer(n int) Option {
+	return func(c *config) { c.watchBuffer = n }
+}
+
+// WithWatchPolicy 设置缓冲区满时的策略（默认 WatchDrop）
+func WithWatchPolicy(p WatchPolicy) Option {
+	return func(c *config) { c.watchPolicy = p }
+}
+
+// Watch 订阅 key 以 prefix 开头的变更（空前缀表示全部，不含 Bucket 内的键）。
+// 事件按写入顺序投递；调用 cancel 或 Close 后 channel 被关闭。
+func (s *KVStore[V]) Watch(prefix string) (<-chan Event[V], func()) {
+	return s.subscribe(prefix, 0)
+}
+
+// subscribe 订阅内部 key 以 prefix 开头的变更，投递时去掉 key 的前 trim 个字节
+func (s *KVStore[V]) subscribe(prefix string, trim int) (<-chan Event[V], func()) {
+	w := &watcher[V]{
+		prefix: prefix,
+		trim:   trim,
+		ch:     make(chan Event[V], s.watch.buffer),
+		done:   make(chan struct{}),
+	}
+	s.watch.mu.Lock()
+	if s.watch.closed {
+		s.watch.mu.Unlock()
+		close(w.ch)
+		return w.ch, func() {}
+	}
+	s.watch.subs[w] = struct{}{}
+	s.watch.active.Add(1)
+	s.watch.mu.Unlock()
+
+	cancel := func() {
+		s.watch.mu.Lock()
+		if _, ok := s.watch.subs[w]; ok {
+			delete(s.watch.subs, w)
+			s.watch.active.Add(-1)
+		}
+		s.watch.mu.Unlock()
+		w.stop()
+	}
+	return w.ch, cancel
+}
+
+// WatchDropped 返回 WatchDrop 策略下被丢弃的事件总数
+func (s *KVStore[V]) WatchDropped() uint64 {
+	return s.watch.dropped.Load()
+}
+
+type watcher[V any] struct {
+	prefix string
+	trim   int
+	ch     chan Event[V]
+	done   chan struct{}
+
+	mu     sync.Mutex
+	once   sync.Once
+	closed bool
+}
+
+func (w *watcher[V]) match(key string) bool {
+	if !strings.HasPrefix(key, w.prefix) {
+		return false
+	}
+	// 根订阅者看不到 Bucket 内的键
+	return w.trim > 0 || !isBucketKey(key)
+}
+
+// stop 先通知阻塞中的发送方退出，再关闭 channel
+func (w *watcher[V]) stop() {
+	w.once.Do(func() {
+		close(w.done)
+		w.mu.Lock()
+		w.closed = true
+		close(w.ch)
+		w.mu.Unlock()
+	})
+}
+
+func (w *watcher[V]) send(ev Event[V], policy WatchPolicy, dropped *atomic.Uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	if policy == WatchBlock {
+		select {
+		case w.ch <- ev:
+		case <-w.done:
+		}
+		return
+	}
+	select {
+	case w.ch <- ev:
+	default:
+		dropped.Add(1)
+	}
+}
+
+// hub 管理订阅者与事件的有序投递
+type hub[V any] struct {
+	mu     sync.Mutex
+	subs   map[*watcher[V]]struct{}
+	closed bool
+	active atomic.Int32
+
+	buffer  int
+	policy  WatchPolicy
+	dropped atomic.Uint64
+
+	// OnEvict 回调（nil 表示未注册）
+	onEvict atomic.Pointer[func(string, V, EvictReason)]
+
+	// 排号投递：持锁期间领号，释放存储锁后按号顺序投递
+	seqMu   sync.Mutex
+	seqCond *sync.Cond
+	next    uint64
+	serving uint64
+}
+
+func newHub[V any](buffer int, policy WatchPolicy) *hub[V] {
+	h := &hub[V]{subs: make(map[*watcher[V]]struct{}), buffer: buffer, policy: policy}
+	h.seqCond = sync.NewCond(&h.seqMu)
+	return h
+}
+
+func (h *hub[V]) ticket() uint64 {
+	h.seqMu.Lock()
+	defer h.seqMu.Unlock()
+	t := h.next
+	h.next++
+	return t
+}
+
+func (h *hub[V]) dispatch(t uint64, events []Event[V]) {
+	h.seqMu.Lock()
+	for h.serving != t {
+		h.seqCond.Wait()
+	}
+	h.seqMu.Unlock()
+
+	h.mu.Lock()
+	subs := make([]*watcher[V], 0, len(h.subs))
+	for w := range h.subs {
+		subs = append(subs, w)
+	}
+	h.mu.Unlock()
+
+	for _, ev := range events {
+		for _, w := range subs {
+			if ev.Type == EventReload {
+				w.send(ev, h.policy, &h.dropped)
+				continue
+			}
+			if w.match(ev.Key) {
+				e := ev
+				e.Key = ev.Key[w.trim:]
+				w.send(e, h.policy, &h.dropped)
+			}
+		}
+	}
+
+	h.seqMu.Lock()
+	h.serving++
+	h.seqCond.Broadcast()
+	h.seqMu.Unlock()
+
+	// 回调在让出投递顺序之后调用，回调中写入同一个 KVStore 不会死锁
+	h.notify(events)
+}
+
+// observed 是否需要记录事件（有订阅者或注册了回调）
+func (h *hub[V]) observed() bool {
+	return h.active.Load() > 0 || h.onEvict.Load() != nil
+}
+
+func (h *hub[V]) close() {
+	h.mu.Lock()
+	subs := h.subs
+	h.subs = make(map[*watcher[V]]struct{})
+	h.closed = true
+	h.active.Store(0)
+	h.mu.Unlock()
+	for w := range subs {
+		w.stop()
+	}
+}
+
+// ---------------------------
+// 与写入路径的衔接
+// ---------------------------
+
+// emitLocked 在持有分片写锁时记录事件，无订阅者与回调时不产生开销
+func (s *KVStore[V]) emitLocked(sh *shard[V], ev Event[V]) {
+	if !s.watch.observed() {
+		return
+	}
+	// Batch 期间按应用顺序统一收集
+	if s.batchEvents != nil {
+		*s.batchEvents = append(*s.batchEvents, ev)
+		return
+	}
+	sh.pending = append(sh.pending, ev)
+}
+
+// release 释放分片写锁，并在锁外按顺序投递本次临界区产生的事件
+func (s *KVStore[V]) release(sh *shard[V]) {
+	if len(sh.pending) == 0 {
+		sh.mu.Unlock()
+		return
+	}
+	events := sh.pending
+	sh.pending = nil
+	t := s.watch.ticket()
+	sh.mu.Unlock()
+	s.watch.dispatch(t, events)
+}
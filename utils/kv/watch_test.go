@@ -0,0 +1,113 @@
+package kv_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+)
+
+func recv[V any](t *testing.T, ch <-chan kv.Event[V]) kv.Event[V] {
+	t.Helper()
+	select {
+	case ev, ok := <-ch:
+		if !ok {
+			t.Fatal("watch channel closed unexpectedly")
+		}
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	panic("unreachable")
+}
+
+func TestKVStore_Watch(t *testing.T) {
+	store, err := kv.NewKVStore[string]("", kv.WithSaveInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ch, cancel := store.Watch("flag:")
+	defer cancel()
+
+	store.Set("other", "x")
+	store.Set("flag:a", "on")
+	store.Set("flag:a", "off")
+	store.Delete("flag:a")
+	store.SetWithTTL("flag:b", "tmp", 5*time.Millisecond)
+
+	ev := recv(t, ch)
+	if ev.Type != kv.EventSet || ev.Key != "flag:a" || ev.HasOld || ev.New != "on" {
+		t.Fatalf("unexpected first event %+v", ev)
+	}
+	ev = recv(t, ch)
+	if ev.Type != kv.EventSet || !ev.HasOld || ev.Old != "on" || ev.New != "off" {
+		t.Fatalf("unexpected overwrite event %+v", ev)
+	}
+	ev = recv(t, ch)
+	if ev.Type != kv.EventDelete || ev.Old != "off" {
+		t.Fatalf("unexpected delete event %+v", ev)
+	}
+	recv(t, ch) // flag:b set
+	ev = recv(t, ch)
+	if ev.Type != kv.EventExpire || ev.Key != "flag:b" || ev.Old != "tmp" {
+		t.Fatalf("unexpected expire event %+v", ev)
+	}
+}
+
+func TestKVStore_WatchDrop(t *testing.T) {
+	store, err := kv.NewKVStore[int]("", kv.WithWatchBuffer(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ch, cancel := store.Watch("")
+	for i := 0; i < 5; i++ {
+		store.Set("k", i)
+	}
+	if n := store.WatchDropped(); n != 3 {
+		t.Fatalf("expected 3 dropped events, got %d", n)
+	}
+	cancel()
+
+	n := 0
+	for range ch {
+		n++
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 buffered events before close, got %d", n)
+	}
+}
+
+func TestKVStore_WatchBlock(t *testing.T) {
+	store, err := kv.NewKVStore[int]("", kv.WithWatchBuffer(0), kv.WithWatchPolicy(kv.WatchBlock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch, _ := store.Watch("")
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			store.Set("k", i)
+		}
+		close(done)
+	}()
+
+	for i := 0; i < 100; i++ {
+		ev := recv(t, ch)
+		if ev.New != i {
+			t.Fatalf("expected ordered event %d, got %d", i, ev.New)
+		}
+		// 订阅者中读取存储不会死锁
+		store.Get("k")
+	}
+	<-done
+
+	store.Close()
+	if _, ok := <-ch; ok {
+		t.Fatal("expected channel closed by Close")
+	}
+}
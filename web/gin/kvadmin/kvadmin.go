@@ -0,0 +1,399 @@
+// Package kvadmin 把运行中的 kv.KVStore 的管理接口挂载到 *gin.RouterGroup 上：
+//
+//	GET    /keys?prefix=&after=&limit=&values=  按 key 升序分页列出
+//	GET    /keys/*key                           读取值、TTL 与标签
+//	PUT    /keys/*key                           写入 {"value": ..., "ttl": "1h", "tags": [...]}
+//	DELETE /keys/*key                           删除
+//	GET    /ttl/*key                            查看过期时间
+//	PUT    /ttl/*key                            修改过期时间 {"ttl": "1h"}，ttl 为空表示不过期
+//	GET    /stats                               运行统计
+//	POST   /save                                立即保存
+//
+// 所有接口都接受 ?bucket=name 以操作 Bucket 内的键（/stats、/save 除外）。
+package kvadmin
+
+import (
+	"encoding/json"
+	"iter"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+	"github.com/Yuelioi/gkit/web/errorx"
+	"github.com/Yuelioi/gkit/web/response"
+	"github.com/gin-gonic/gin"
+)
+
+type config struct {
+	authorizers []gin.HandlerFunc
+	noAuth      bool
+	readOnly    bool
+	traceIDKey  string
+	maxLimit    int
+}
+
+// Option 定义配置函数类型
+type Option func(*config)
+
+// WithAuthorizer 设置鉴权中间件（如 apikey.Default），按顺序在所有接口前执行
+func WithAuthorizer(h ...gin.HandlerFunc) Option {
+	return func(c *config) {
+		c.authorizers = append(c.authorizers, h...)
+	}
+}
+
+// WithoutAuth 明确不做鉴权（例如 rg 已经挂了鉴权中间件）
+func WithoutAuth() Option {
+	return func(c *config) {
+		c.noAuth = true
+	}
+}
+
+// WithReadOnly 只挂载读取接口
+func WithReadOnly() Option {
+	return func(c *config) {
+		c.readOnly = true
+	}
+}
+
+// WithTraceIDKey 设置从 gin.Context 读取 TraceID 的键（默认 "request_id"，与 requestid 中间件一致）
+func WithTraceIDKey(key string) Option {
+	return func(c *config) {
+		c.traceIDKey = key
+	}
+}
+
+// WithMaxLimit 设置列表接口单页的最大条数（默认 1000）
+func WithMaxLimit(n int) Option {
+	return func(c *config) {
+		c.maxLimit = n
+	}
+}
+
+// Mount 把 store 的管理接口挂载到 rg。
+// 必须通过 WithAuthorizer 设置鉴权，或用 WithoutAuth 明确放弃鉴权，否则 panic。
+func Mount[V any](rg *gin.RouterGroup, store *kv.KVStore[V], opts ...Option) {
+	// 默认值
+	cfg := &config{
+		traceIDKey: "request_id",
+		maxLimit:   1000,
+	}
+
+	// 应用 Option
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if len(cfg.authorizers) == 0 && !cfg.noAuth {
+		panic("kvadmin: Authorizer is required (use WithoutAuth to disable)")
+	}
+
+	h := &handler[V]{store: store, cfg: cfg}
+	g := rg.Group("", cfg.authorizers...)
+	g.GET("/keys", h.list)
+	g.GET("/keys/*key", h.get)
+	g.GET("/ttl/*key", h.ttl)
+	g.GET("/stats", h.stats)
+	if cfg.readOnly {
+		return
+	}
+	g.PUT("/keys/*key", h.set)
+	g.DELETE("/keys/*key", h.delete)
+	g.PUT("/ttl/*key", h.expire)
+	g.POST("/save", h.save)
+}
+
+type handler[V any] struct {
+	store *kv.KVStore[V]
+	cfg   *config
+}
+
+// ---------------------------
+// 响应结构
+// ---------------------------
+
+// Item 列表中的一条记录
+type Item struct {
+	Key   string `json:"key"`
+	Value any    `json:"value,omitempty"`
+}
+
+// Page 列表结果，Next 非空时可作为下一页的 after 参数
+type Page struct {
+	Items []Item `json:"items"`
+	Next  string `json:"next,omitempty"`
+}
+
+// TTL 过期信息
+type TTL struct {
+	State       string     `json:"state"` // "no_expiry" 或 "expiring"
+	RemainingMs int64      `json:"remaining_ms,omitempty"`
+	ExpireAt    *time.Time `json:"expire_at,omitempty"`
+}
+
+// Entry 单个键的详情
+type Entry struct {
+	Key   string   `json:"key"`
+	Value any      `json:"value"`
+	TTL   TTL      `json:"ttl"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+// Stats 运行统计，字段含义见 kv.Stats
+type Stats struct {
+	Hits               uint64     `json:"hits"`
+	Misses             uint64     `json:"misses"`
+	Sets               uint64     `json:"sets"`
+	Deletes            uint64     `json:"deletes"`
+	Expirations        uint64     `json:"expirations"`
+	Evictions          uint64     `json:"evictions"`
+	Keys               int64      `json:"keys"`
+	LastSave           *time.Time `json:"last_save,omitempty"`
+	LastSaveDurationMs float64    `json:"last_save_duration_ms"`
+	SaveErrors         uint64     `json:"save_errors"`
+	FileSize           int64      `json:"file_size"`
+	WALSize            int64      `json:"wal_size"`
+	WatchDropped       uint64     `json:"watch_dropped"`
+}
+
+// ---------------------------
+// 接口
+// ---------------------------
+
+func (h *handler[V]) reply(c *gin.Context, r *response.Response) {
+	if id := c.GetString(h.cfg.traceIDKey); id != "" {
+		r.WithTraceID(id)
+	}
+	r.GJSON(c)
+}
+
+func (h *handler[V]) fail(c *gin.Context, err *errorx.Error) {
+	h.reply(c, response.Error(err))
+}
+
+// key 解析路径中的 key 与 bucket 参数，返回存储中的内部 key
+func (h *handler[V]) key(c *gin.Context) (string, bool) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if key == "" {
+		h.fail(c, errorx.MissingParams.WithMessage("key is required"))
+		return "", false
+	}
+	bucket, ok := h.bucket(c)
+	if !ok {
+		return "", false
+	}
+	if bucket != "" {
+		key = kv.BucketKey(bucket, key)
+	}
+	return key, true
+}
+
+func (h *handler[V]) bucket(c *gin.Context) (string, bool) {
+	bucket, given := c.GetQuery("bucket")
+	if given && (bucket == "" || strings.Contains(bucket, "\x00")) {
+		h.fail(c, errorx.InvalidParams.WithMessage("invalid bucket name"))
+		return "", false
+	}
+	return bucket, true
+}
+
+func (h *handler[V]) list(c *gin.Context) {
+	bucket, ok := h.bucket(c)
+	if !ok {
+		return
+	}
+	limit := 100
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			h.fail(c, errorx.InvalidParams.WithMessage("invalid limit"))
+			return
+		}
+		limit = n
+	}
+	limit = min(limit, h.cfg.maxLimit)
+	values := c.Query("values") == "true" || c.Query("values") == "1"
+
+	// 多取一条判断是否还有下一页；Bucket 按名称扫描，不为请求中的名称创建 Bucket 实例
+	scanOpts := []kv.ScanOption{kv.After(c.Query("after")), kv.Limit(limit + 1)}
+	var seq iter.Seq2[string, V]
+	if bucket != "" {
+		seq = h.store.ScanBucket(bucket, c.Query("prefix"), scanOpts...)
+	} else {
+		seq = h.store.Scan(c.Query("prefix"), scanOpts...)
+	}
+	page := Page{Items: []Item{}}
+	for k, v := range seq {
+		if len(page.Items) == limit {
+			page.Next = page.Items[limit-1].Key
+			break
+		}
+		item := Item{Key: k}
+		if values {
+			item.Value = v
+		}
+		page.Items = append(page.Items, item)
+	}
+	h.reply(c, response.Success(page))
+}
+
+func (h *handler[V]) get(c *gin.Context) {
+	key, ok := h.key(c)
+	if !ok {
+		return
+	}
+	v, found := h.store.Get(key)
+	if !found {
+		h.fail(c, errorx.NotFound.WithMessage("key not found"))
+		return
+	}
+	_, name, _ := kv.ParseBucketKey(key)
+	h.reply(c, response.Success(Entry{
+		Key:   name,
+		Value: v,
+		TTL:   toTTL(h.store.TTL(key)),
+		Tags:  h.store.Tags(key),
+	}))
+}
+
+type setRequest struct {
+	Value json.RawMessage `json:"value"`
+	TTL   string          `json:"ttl"`
+	Tags  []string        `json:"tags"`
+}
+
+func (h *handler[V]) set(c *gin.Context) {
+	key, ok := h.key(c)
+	if !ok {
+		return
+	}
+	var req setRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Value) == 0 {
+		h.fail(c, errorx.InvalidFormat.WithMessage("body must be {\"value\": ...}"))
+		return
+	}
+	var v V
+	if err := json.Unmarshal(req.Value, &v); err != nil {
+		h.fail(c, errorx.ValidationFailed.WithMessage("invalid value: "+err.Error()))
+		return
+	}
+	ttl, ok := h.duration(c, req.TTL)
+	if !ok {
+		return
+	}
+	if len(req.Tags) > 0 {
+		h.store.SetWithTags(key, v, ttl, req.Tags...)
+	} else {
+		h.store.SetWithTTL(key, v, ttl)
+	}
+	h.reply(c, response.Success(nil))
+}
+
+func (h *handler[V]) delete(c *gin.Context) {
+	key, ok := h.key(c)
+	if !ok {
+		return
+	}
+	if !h.store.Exists(key) {
+		h.fail(c, errorx.NotFound.WithMessage("key not found"))
+		return
+	}
+	h.store.Delete(key)
+	h.reply(c, response.Success(nil))
+}
+
+func (h *handler[V]) ttl(c *gin.Context) {
+	key, ok := h.key(c)
+	if !ok {
+		return
+	}
+	r := h.store.TTL(key)
+	if !r.Exists() {
+		h.fail(c, errorx.NotFound.WithMessage("key not found"))
+		return
+	}
+	h.reply(c, response.Success(toTTL(r)))
+}
+
+type expireRequest struct {
+	TTL string `json:"ttl"`
+}
+
+func (h *handler[V]) expire(c *gin.Context) {
+	key, ok := h.key(c)
+	if !ok {
+		return
+	}
+	var req expireRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.fail(c, errorx.InvalidFormat.WithMessage("body must be {\"ttl\": \"1h\"}"))
+		return
+	}
+	ttl, ok := h.duration(c, req.TTL)
+	if !ok {
+		return
+	}
+	var found bool
+	if ttl == 0 {
+		found = h.store.Persist(key) || h.store.TTL(key).State == kv.TTLNoExpiry
+	} else {
+		found = h.store.Expire(key, ttl)
+	}
+	if !found {
+		h.fail(c, errorx.NotFound.WithMessage("key not found"))
+		return
+	}
+	h.reply(c, response.Success(toTTL(h.store.TTL(key))))
+}
+
+func (h *handler[V]) stats(c *gin.Context) {
+	st := h.store.Stats()
+	out := Stats{
+		Hits:               st.Hits,
+		Misses:             st.Misses,
+		Sets:               st.Sets,
+		Deletes:            st.Deletes,
+		Expirations:        st.Expirations,
+		Evictions:          st.Evictions,
+		Keys:               st.Keys,
+		LastSaveDurationMs: float64(st.LastSaveDuration) / float64(time.Millisecond),
+		SaveErrors:         st.SaveErrors,
+		FileSize:           st.FileSize,
+		WALSize:            st.WALSize,
+		WatchDropped:       st.WatchDropped,
+	}
+	if !st.LastSave.IsZero() {
+		out.LastSave = &st.LastSave
+	}
+	h.reply(c, response.Success(out))
+}
+
+func (h *handler[V]) save(c *gin.Context) {
+	if err := h.store.Save(); err != nil {
+		h.fail(c, errorx.Internal.WithMessage("save failed: "+err.Error()).WithCause(err))
+		return
+	}
+	h.reply(c, response.Success(nil))
+}
+
+// duration 解析 ttl 参数，空字符串表示不过期
+func (h *handler[V]) duration(c *gin.Context, s string) (time.Duration, bool) {
+	if s == "" {
+		return 0, true
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		h.fail(c, errorx.InvalidParams.WithMessage("ttl must be a positive duration such as \"30s\" or \"1h\""))
+		return 0, false
+	}
+	return d, true
+}
+
+func toTTL(r kv.TTLResult) TTL {
+	if r.State != kv.TTLExpiring {
+		return TTL{State: "no_expiry"}
+	}
+	at := time.Now().Add(r.Remaining).UTC()
+	return TTL{State: "expiring", RemainingMs: r.Remaining.Milliseconds(), ExpireAt: &at}
+}
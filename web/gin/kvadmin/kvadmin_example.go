@@ -0,0 +1,20 @@
+package kvadmin
+
+import (
+	"github.com/Yuelioi/gkit/utils/kv"
+	"github.com/Yuelioi/gkit/web/gin/middleware/apikey"
+	"github.com/gin-gonic/gin"
+)
+
+func Example(r *gin.Engine, store *kv.KVStore[map[string]any]) {
+
+	// 使用 apikey 中间件鉴权（推荐）
+	Mount(r.Group("/admin/kv"), store, WithAuthorizer(apikey.Default(func(k string) bool {
+		return k == "my-secret"
+	})))
+
+	// 只读，鉴权由上层路由组负责
+	admin := r.Group("/internal", apikey.Default(func(k string) bool { return k == "12345" }))
+	Mount(admin.Group("/kv"), store, WithoutAuth(), WithReadOnly())
+
+}
@@ -0,0 +1,196 @@
+package kvadmin_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Yuelioi/gkit/utils/kv"
+	"github.com/Yuelioi/gkit/web/gin/kvadmin"
+	"github.com/gin-gonic/gin"
+)
+
+func newServer(t *testing.T, opts ...kvadmin.Option) (*gin.Engine, *kv.KVStore[int]) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	store, err := kv.NewKVStore[int]("", kv.WithSaveInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	r := gin.New()
+	kvadmin.Mount(r.Group("/kv"), store, append([]kvadmin.Option{kvadmin.WithoutAuth()}, opts...)...)
+	return r, store
+}
+
+// do 发送请求并把响应中的 data 解码到 out（out 为 nil 时忽略）
+func do(t *testing.T, r http.Handler, method, url, body string, out any) int {
+	t.Helper()
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if out != nil && w.Code == http.StatusOK {
+		var resp struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode %s %s: %v (%s)", method, url, err, w.Body)
+		}
+		if err := json.Unmarshal(resp.Data, out); err != nil {
+			t.Fatalf("decode data %s %s: %v (%s)", method, url, err, resp.Data)
+		}
+	}
+	return w.Code
+}
+
+func keysOf(p kvadmin.Page) []string {
+	keys := make([]string, 0, len(p.Items))
+	for _, it := range p.Items {
+		keys = append(keys, it.Key)
+	}
+	return keys
+}
+
+func TestMount_RequiresAuthorizer(t *testing.T) {
+	store, _ := kv.NewKVStore[int]("")
+	defer store.Close()
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic without authorizer")
+		}
+	}()
+	kvadmin.Mount(gin.New().Group("/kv"), store)
+}
+
+func TestList_Paging(t *testing.T) {
+	r, store := newServer(t)
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		store.Set(k, 1)
+	}
+	store.Bucket("users").Set("alice", 1)
+
+	var page kvadmin.Page
+	if code := do(t, r, "GET", "/kv/keys?limit=2", "", &page); code != http.StatusOK {
+		t.Fatalf("unexpected status %d", code)
+	}
+	if got := strings.Join(keysOf(page), ","); got != "a,b" || page.Next != "b" {
+		t.Fatalf("unexpected first page %q next %q", got, page.Next)
+	}
+	page = kvadmin.Page{}
+	do(t, r, "GET", "/kv/keys?limit=2&after=d", "", &page)
+	if got := strings.Join(keysOf(page), ","); got != "e" || page.Next != "" {
+		t.Fatalf("unexpected last page %q next %q", got, page.Next)
+	}
+
+	// Bucket 内的键不出现在根列表中，按 ?bucket= 列出且不含前缀
+	page = kvadmin.Page{}
+	do(t, r, "GET", "/kv/keys?values=1&bucket=users", "", &page)
+	if len(page.Items) != 1 || page.Items[0].Key != "alice" || page.Items[0].Value != float64(1) {
+		t.Fatalf("unexpected bucket page %+v", page)
+	}
+	if code := do(t, r, "GET", "/kv/keys?bucket=", "", nil); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for empty bucket, got %d", code)
+	}
+	if code := do(t, r, "GET", "/kv/keys?bucket=a%00b", "", nil); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for NUL bucket, got %d", code)
+	}
+	if code := do(t, r, "GET", "/kv/keys?limit=0", "", nil); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid limit, got %d", code)
+	}
+}
+
+func TestKeys_GetSetDelete(t *testing.T) {
+	r, store := newServer(t)
+
+	if code := do(t, r, "PUT", "/kv/keys/a/b", `{"value": 7, "ttl": "1h", "tags": ["t"]}`, nil); code != http.StatusOK {
+		t.Fatalf("set failed: %d", code)
+	}
+	if v, ok := store.Get("a/b"); !ok || v != 7 {
+		t.Fatalf("expected a/b=7, got %v %v", v, ok)
+	}
+	var e kvadmin.Entry
+	if code := do(t, r, "GET", "/kv/keys/a/b", "", &e); code != http.StatusOK {
+		t.Fatalf("get failed: %d", code)
+	}
+	if e.Key != "a/b" || e.Value != float64(7) || e.TTL.State != "expiring" || len(e.Tags) != 1 {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+
+	if code := do(t, r, "PUT", "/kv/keys/x?bucket=users", `{"value": 1}`, nil); code != http.StatusOK {
+		t.Fatalf("bucket set failed: %d", code)
+	}
+	if _, ok := store.Bucket("users").Get("x"); !ok {
+		t.Fatal("expected key written into bucket")
+	}
+
+	if code := do(t, r, "PUT", "/kv/keys/bad", `{"value": "str"}`, nil); code == http.StatusOK {
+		t.Fatal("expected invalid value rejected")
+	}
+	if code := do(t, r, "DELETE", "/kv/keys/a/b", "", nil); code != http.StatusOK {
+		t.Fatalf("delete failed: %d", code)
+	}
+	if code := do(t, r, "DELETE", "/kv/keys/a/b", "", nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404 deleting missing key, got %d", code)
+	}
+	if code := do(t, r, "GET", "/kv/keys/a/b", "", nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for missing key, got %d", code)
+	}
+}
+
+func TestTTL_GetPut(t *testing.T) {
+	r, store := newServer(t)
+	store.SetWithTTL("k", 1, time.Hour)
+	store.Set("forever", 1)
+
+	var ttl kvadmin.TTL
+	do(t, r, "GET", "/kv/ttl/k", "", &ttl)
+	if ttl.State != "expiring" || ttl.RemainingMs <= 0 {
+		t.Fatalf("unexpected ttl %+v", ttl)
+	}
+
+	ttl = kvadmin.TTL{}
+	if code := do(t, r, "PUT", "/kv/ttl/forever", `{"ttl": "30s"}`, &ttl); code != http.StatusOK || ttl.State != "expiring" {
+		t.Fatalf("expected ttl set, got %d %+v", code, ttl)
+	}
+	// ttl 为空表示不过期：带过期时间的键被 Persist，本就不过期的键也视为成功
+	ttl = kvadmin.TTL{}
+	if code := do(t, r, "PUT", "/kv/ttl/k", `{"ttl": ""}`, &ttl); code != http.StatusOK || ttl.State != "no_expiry" {
+		t.Fatalf("expected persist, got %d %+v", code, ttl)
+	}
+	if code := do(t, r, "PUT", "/kv/ttl/k", `{}`, nil); code != http.StatusOK {
+		t.Fatalf("expected persist on no-expiry key to succeed, got %d", code)
+	}
+	if st := store.TTL("k"); st.State != kv.TTLNoExpiry {
+		t.Fatalf("expected k persisted, got %+v", st)
+	}
+
+	if code := do(t, r, "PUT", "/kv/ttl/missing", `{}`, nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404 persisting missing key, got %d", code)
+	}
+	if code := do(t, r, "GET", "/kv/ttl/missing", "", nil); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for missing ttl, got %d", code)
+	}
+	if code := do(t, r, "PUT", "/kv/ttl/k", `{"ttl": "-1s"}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for negative ttl, got %d", code)
+	}
+}
+
+func TestMount_ReadOnly(t *testing.T) {
+	r, store := newServer(t, kvadmin.WithReadOnly())
+	store.Set("k", 1)
+	if code := do(t, r, "GET", "/kv/keys/k", "", nil); code != http.StatusOK {
+		t.Fatalf("expected read allowed, got %d", code)
+	}
+	if code := do(t, r, "DELETE", "/kv/keys/k", "", nil); code != http.StatusNotFound {
+		t.Fatalf("expected write route not mounted, got %d", code)
+	}
+	if !store.Exists("k") {
+		t.Fatal("expected key kept")
+	}
+}